@@ -0,0 +1,32 @@
+package echootelmiddleware
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+const defaultBaggageAttributePrefix = "baggage."
+
+// baggageAttrs converts the baggage members of ctx to span attributes. With a
+// BaggageAllowlist only the listed members are kept.
+func baggageAttrs(ctx context.Context, config OtelConfig) []attribute.KeyValue {
+	members := baggage.FromContext(ctx).Members()
+	if len(members) == 0 {
+		return nil
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(members))
+
+	for _, m := range members {
+		if len(config.BaggageAllowlist) > 0 && !slices.Contains(config.BaggageAllowlist, m.Key()) {
+			continue
+		}
+
+		attrs = append(attrs, attribute.String(config.BaggageAttributePrefix+m.Key(), m.Value()))
+	}
+
+	return attrs
+}
@@ -0,0 +1,89 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBaggageDump(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  OtelConfig
+		want    []attribute.KeyValue
+		notWant []attribute.Key
+	}{
+		{
+			name:   "disabled by default",
+			config: OtelConfig{},
+			notWant: []attribute.Key{
+				"baggage.tenant.id",
+				"baggage.experiment",
+			},
+		},
+		{
+			name:   "all members",
+			config: OtelConfig{IsBaggageDump: true},
+			want: []attribute.KeyValue{
+				attribute.String("baggage.tenant.id", "acme"),
+				attribute.String("baggage.experiment", "checkout-v2"),
+			},
+		},
+		{
+			name: "allowlist and prefix",
+			config: OtelConfig{
+				IsBaggageDump:          true,
+				BaggageAllowlist:       []string{"tenant.id"},
+				BaggageAttributePrefix: "app.",
+			},
+			want:    []attribute.KeyValue{attribute.String("app.tenant.id", "acme")},
+			notWant: []attribute.Key{"app.experiment", "baggage.tenant.id"},
+		},
+		{
+			name: "size limits",
+			config: OtelConfig{
+				IsBaggageDump:    true,
+				BaggageAllowlist: []string{"experiment"},
+				LimitValueSize:   8,
+			},
+			want: []attribute.KeyValue{attribute.String("baggage.experiment", "checkout")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			tc.config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+			tc.config.Propagator = propagation.Baggage{}
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(tc.config))
+			router.GET("/", func(c *echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			r.Header.Set("Baggage", "tenant.id=acme,experiment=checkout-v2")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			attrs := spans[0].Attributes()
+
+			for _, want := range tc.want {
+				assert.Contains(t, attrs, want)
+			}
+
+			for _, key := range tc.notWant {
+				assert.False(t, hasAttrPrefix(attrs, string(key)), key)
+			}
+		})
+	}
+}
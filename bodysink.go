@@ -0,0 +1,91 @@
+package echootelmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// BodySink selects where captured request and response bodies are recorded
+// when IsBodyDump is enabled.
+type BodySink uint8
+
+const (
+	// AttributeBodySink records bodies as span attributes, subject to
+	// LimitValueSize.
+	AttributeBodySink BodySink = iota
+
+	// EventBodySink records each body as a span event named after the body
+	// attribute key, holding the content in that key. Size limits are not
+	// applied.
+	EventBodySink
+
+	// LogBodySink emits each body as an OpenTelemetry log record through
+	// LoggerProvider. Records are emitted with the span context, so they
+	// carry its trace and span IDs. Size limits are not applied.
+	LogBodySink
+)
+
+// isBodyMarker reports whether a dumped body is a marker value rather than
+// captured content. Markers are always kept as span attributes.
+func isBodyMarker(value string) bool {
+	switch value {
+	case bodyExcluded, bodyReadError, bodyNonText:
+		return true
+	}
+
+	return false
+}
+
+// sinkBody records a captured body in config.BodySink and returns the span
+// attributes standing in for it: the body itself for AttributeBodySink, the
+// number of captured body bytes and the SHA-256 of the recorded content
+// otherwise. The ValueScrubber is applied before the body leaves the span.
+func sinkBody(c *echo.Context, config OtelConfig, span oteltrace.Span, captured capturedBody, sizeKey, hashKey string) []attribute.KeyValue {
+	body := captured.attr
+
+	content := body.Value.AsString()
+	if config.BodySink == AttributeBodySink || isBodyMarker(content) {
+		return []attribute.KeyValue{body}
+	}
+
+	if config.ValueScrubber != nil {
+		content = config.ValueScrubber(string(body.Key), content)
+	}
+
+	switch config.BodySink {
+	case EventBodySink:
+		span.AddEvent(string(body.Key), oteltrace.WithAttributes(body.Key.String(content)))
+	case LogBodySink:
+		emitBodyLog(oteltrace.ContextWithSpan(c.Request().Context(), span), config, string(body.Key), content)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+
+	return []attribute.KeyValue{
+		attribute.Int(sizeKey, captured.size),
+		attribute.String(hashKey, hex.EncodeToString(sum[:])),
+	}
+}
+
+// emitBodyLog emits a body as a log record named after its attribute key.
+func emitBodyLog(ctx context.Context, config OtelConfig, name, content string) {
+	logger := config.LoggerProvider.Logger(tracerName)
+	if !logger.Enabled(ctx, otellog.EnabledParameters{Severity: otellog.SeverityInfo, EventName: name}) {
+		return
+	}
+
+	var record otellog.Record
+	record.SetEventName(name)
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetBody(attribute.StringValue(content))
+
+	logger.Emit(ctx, record)
+}
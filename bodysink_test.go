@@ -0,0 +1,157 @@
+package echootelmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// logRecorder is an in-memory sdklog.Exporter.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (r *logRecorder) Export(_ context.Context, records []sdklog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range records {
+		r.records = append(r.records, record.Clone())
+	}
+
+	return nil
+}
+
+func (*logRecorder) Shutdown(context.Context) error   { return nil }
+func (*logRecorder) ForceFlush(context.Context) error { return nil }
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func serveBodySink(t *testing.T, config OtelConfig) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	config.IsBodyDump = true
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(config))
+	router.POST("/", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "pong: alice@example.com")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ping: alice@example.com"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+
+	return spans[0]
+}
+
+func TestEventBodySink(t *testing.T) {
+	span := serveBodySink(t, OtelConfig{
+		BodySink:       EventBodySink,
+		LimitValueSize: 8,
+	})
+
+	attrs := span.Attributes()
+	assert.NotContains(t, attrs, attribute.String("http.request.body", "ping: al"))
+	assert.NotContains(t, attrs, attribute.String("http.response.body", "pong: al"))
+	assert.Contains(t, attrs, attribute.Int("http.request.body.dump.size", len("ping: alice@example.com")))
+	assert.Contains(t, attrs, attribute.String("http.request.body.dump.sha256", sha256Hex("ping: alice@example.com")[:8]))
+	assert.Contains(t, attrs, attribute.Int("http.response.body.dump.size", len("pong: alice@example.com")))
+	assert.Contains(t, attrs, attribute.String("http.response.body.dump.sha256", sha256Hex("pong: alice@example.com")[:8]))
+
+	// Size limits apply to span attributes, not to the sunk bodies.
+	events := span.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "http.request.body", events[0].Name)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("http.request.body", "ping: alice@example.com")}, events[0].Attributes)
+	assert.Equal(t, "http.response.body", events[1].Name)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("http.response.body", "pong: alice@example.com")}, events[1].Attributes)
+}
+
+func TestLogBodySink(t *testing.T) {
+	exporter := &logRecorder{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+
+	span := serveBodySink(t, OtelConfig{
+		BodySink:        LogBodySink,
+		LoggerProvider:  provider,
+		AttributeSchema: SemconvSchema,
+		ValueScrubber:   NewValueScrubber([]Detector{EmailDetector()}),
+	})
+
+	attrs := span.Attributes()
+	assert.NotContains(t, attrs, attribute.String("http.request.body.content", "ping: [redacted]"))
+	assert.NotContains(t, attrs, attribute.String("http.response.body.content", "pong: [redacted]"))
+	// Keys follow the AttributeSchema; the size is the captured byte count,
+	// not the length of the scrubbed content.
+	assert.Contains(t, attrs, attribute.Int("http.request.body.content.size", len("ping: alice@example.com")))
+	assert.Contains(t, attrs, attribute.String("http.request.body.content.sha256", sha256Hex("ping: [redacted]")))
+	assert.Contains(t, attrs, attribute.Int("http.response.body.content.size", len("pong: alice@example.com")))
+	assert.Contains(t, attrs, attribute.String("http.response.body.content.sha256", sha256Hex("pong: [redacted]")))
+	assert.False(t, hasAttrPrefix(attrs, "http.request.body.dump"))
+	assert.Empty(t, span.Events())
+
+	require.Len(t, exporter.records, 2)
+
+	bodies := map[string]string{}
+	for _, record := range exporter.records {
+		assert.Equal(t, span.SpanContext().TraceID(), record.TraceID())
+		assert.Equal(t, span.SpanContext().SpanID(), record.SpanID())
+		bodies[record.EventName()] = record.Body().AsString()
+	}
+
+	assert.Equal(t, map[string]string{
+		"http.request.body.content":  "ping: [redacted]",
+		"http.response.body.content": "pong: [redacted]",
+	}, bodies)
+}
+
+func TestBodySinkKeepsMarkers(t *testing.T) {
+	span := serveBodySink(t, OtelConfig{
+		BodySink: EventBodySink,
+		BodySkipper: func(*echo.Context) (bool, bool) {
+			return false, true
+		},
+	})
+
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, attribute.String("http.response.body", bodyExcluded))
+	assert.False(t, hasAttrPrefix(attrs, "http.response.body.dump"))
+	assert.True(t, hasAttrPrefix(attrs, "http.request.body.dump.sha256"))
+}
+
+func TestBodySinkSizeOfTruncatedBody(t *testing.T) {
+	span := serveBodySink(t, OtelConfig{
+		BodySink:        EventBodySink,
+		MaxBodyDumpSize: 4,
+	})
+
+	// The marker appended to truncated bodies is not counted.
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, attribute.Int("http.request.body.dump.size", 4))
+	assert.Contains(t, attrs, attribute.String("http.request.body.dump.sha256", sha256Hex("ping"+bodyTruncated)))
+	assert.Contains(t, attrs, attribute.Int("http.response.body.dump.size", 4))
+}
@@ -0,0 +1,93 @@
+package echootelmiddleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	headerContentEncoding = "Content-Encoding"
+
+	attrRequestBodyEncoding  = "http.request.body.encoding"
+	attrResponseBodyEncoding = "http.response.body.encoding"
+)
+
+// contentEncoding returns the normalized Content-Encoding header value, or ""
+// for identity.
+func contentEncoding(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "identity" {
+		return ""
+	}
+
+	return value
+}
+
+// decodeBody decompresses a captured body according to its Content-Encoding
+// (gzip, deflate or br; a list is decoded in reverse order). The handler's
+// stream is not affected. At most maxSize decompressed bytes are returned
+// (defaultMaxBodyDumpSize if maxSize <= 0); truncated reports whether the
+// output was cut, either by the limit or because the compressed input was
+// itself truncated. ok is false for unsupported encodings or corrupt data.
+func decodeBody(encoding string, body []byte, truncated bool, maxSize int64) ([]byte, bool, bool) {
+	if maxSize <= 0 {
+		// Even with unlimited dumps, never inflate a body without bound.
+		maxSize = defaultMaxBodyDumpSize
+	}
+
+	codings := strings.Split(encoding, ",")
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		r, err := decompressor(strings.TrimSpace(codings[i]), body)
+		if err != nil {
+			return nil, false, false
+		}
+
+		// Read one extra byte to detect truncation.
+		out, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+		if int64(len(out)) > maxSize {
+			out, truncated, err = out[:maxSize], true, nil
+		}
+
+		switch {
+		case err == nil:
+		case truncated && errors.Is(err, io.ErrUnexpectedEOF):
+			// The captured prefix ended mid-stream; keep what was decoded.
+		default:
+			return nil, false, false
+		}
+
+		body = out
+	}
+
+	return body, truncated, true
+}
+
+// decompressor returns a reader decompressing body with the given coding.
+func decompressor(coding string, body []byte) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// "deflate" is zlib-wrapped per RFC 9110, but some servers send raw
+		// deflate data.
+		if r, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			return r, nil
+		}
+
+		return flate.NewReader(bytes.NewReader(body)), nil
+	case "br":
+		return brotli.NewReader(bytes.NewReader(body)), nil
+	case "", "identity":
+		return bytes.NewReader(body), nil
+	}
+
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,179 @@
+package echootelmiddleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	plain := []byte(`{"message":"hello, compressed world"}`)
+
+	for _, coding := range []string{"gzip", "deflate", "raw-deflate", "br"} {
+		t.Run(coding, func(t *testing.T) {
+			encoding := coding
+			if coding == "raw-deflate" {
+				encoding = "deflate"
+			}
+
+			decoded, truncated, ok := decodeBody(encoding, compress(t, coding, plain), false, 1024)
+			require.True(t, ok)
+			assert.False(t, truncated)
+			assert.Equal(t, plain, decoded)
+		})
+	}
+
+	t.Run("stacked codings", func(t *testing.T) {
+		body := compress(t, "br", compress(t, "gzip", plain))
+
+		decoded, _, ok := decodeBody("gzip, br", body, false, 1024)
+		require.True(t, ok)
+		assert.Equal(t, plain, decoded)
+	})
+
+	t.Run("output limit", func(t *testing.T) {
+		bomb := compress(t, "gzip", make([]byte, 10<<20))
+
+		decoded, truncated, ok := decodeBody("gzip", bomb, false, 16)
+		require.True(t, ok)
+		assert.True(t, truncated)
+		assert.Len(t, decoded, 16)
+	})
+
+	t.Run("output limit with unlimited dumps", func(t *testing.T) {
+		for _, coding := range []string{"gzip", "br"} {
+			bomb := compress(t, coding, make([]byte, 10<<20))
+
+			decoded, truncated, ok := decodeBody(coding, bomb, false, -1)
+			require.True(t, ok)
+			assert.True(t, truncated)
+			assert.Len(t, decoded, int(defaultMaxBodyDumpSize))
+		}
+	})
+
+	t.Run("truncated input", func(t *testing.T) {
+		body := compress(t, "gzip", []byte(strings.Repeat("abcdefgh", 1000)))
+
+		decoded, truncated, ok := decodeBody("gzip", body[:len(body)/2], true, 0)
+		require.True(t, ok)
+		assert.True(t, truncated)
+		assert.True(t, strings.HasPrefix(strings.Repeat("abcdefgh", 1000), string(decoded)))
+	})
+
+	t.Run("corrupt data", func(t *testing.T) {
+		_, _, ok := decodeBody("gzip", []byte("not gzip"), false, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("unsupported coding", func(t *testing.T) {
+		_, _, ok := decodeBody("zstd", plain, false, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestCompressedBodies(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	reqBody := compress(t, "gzip", []byte(`{"name":"bob"}`))
+	respBody := compress(t, "br", []byte(`{"greeting":"hi bob"}`))
+
+	var received []byte
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsBodyDump:     true,
+	}))
+	router.POST("/greet", func(c *echo.Context) error {
+		var err error
+
+		received, err = io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+
+		c.Response().Header().Set(headerContentEncoding, "br")
+
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, respBody)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/greet", bytes.NewReader(reqBody))
+	r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	r.Header.Set(headerContentEncoding, "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	// The handler and the client see the compressed streams.
+	assert.Equal(t, reqBody, received)
+	assert.Equal(t, respBody, w.Body.Bytes())
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("http.request.body", `{"name":"bob"}`))
+	assert.Contains(t, attrs, attribute.String("http.request.body.encoding", "gzip"))
+	assert.Contains(t, attrs, attribute.String("http.response.body", `{"greeting":"hi bob"}`))
+	assert.Contains(t, attrs, attribute.String("http.response.body.encoding", "br"))
+}
+
+func TestCompressedBodyLimit(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:  sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsBodyDump:      true,
+		MaxBodyDumpSize: 64,
+	}))
+	router.POST("/", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(compress(t, "deflate", []byte(strings.Repeat("a", 4096)))))
+	r.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+	r.Header.Set(headerContentEncoding, "deflate")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	// The compressed body fits the limit; its decompressed output does not.
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("http.request.body", strings.Repeat("a", 64)+"[truncated]"))
+}
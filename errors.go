@@ -0,0 +1,96 @@
+package echootelmiddleware
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrorTypeClassifier returns the error.type attribute value for a finished
+// request, or "" if the request did not fail.
+type ErrorTypeClassifier func(c *echo.Context, status int, err error) string
+
+// Attribute keys for the details of an *echo.HTTPError.
+const (
+	attrHTTPErrorCode     = "echo.http_error.code"
+	attrHTTPErrorMessage  = "echo.http_error.message"
+	attrHTTPErrorInternal = "echo.http_error.internal"
+)
+
+// GoTypeErrorClassifier uses the Go type of the handler error (e.g.
+// "*echo.HTTPError"). Requests without an error that end with a 5xx status
+// use the status code, as recommended by the semantic conventions. This is
+// the default.
+func GoTypeErrorClassifier(_ *echo.Context, status int, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%T", err)
+	}
+
+	if status >= 500 {
+		return strconv.Itoa(status)
+	}
+
+	return ""
+}
+
+// StatusClassErrorClassifier uses the HTTP status class ("4xx", "5xx") for
+// requests that returned an error or ended with a 5xx status, and "_OTHER"
+// when the handler failed without a known status.
+func StatusClassErrorClassifier(_ *echo.Context, status int, err error) string {
+	if err == nil && status < 500 {
+		return ""
+	}
+
+	if status < 100 || status > 599 {
+		return "_OTHER"
+	}
+
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// httpErrorAttrs returns the code, message and wrapped internal error of an
+// *echo.HTTPError found in err's chain.
+func httpErrorAttrs(err error) []attribute.KeyValue {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		return nil
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.Int(attrHTTPErrorCode, he.Code),
+		attribute.String(attrHTTPErrorMessage, he.Message),
+	}
+
+	if internal := he.Unwrap(); internal != nil {
+		attrs = append(attrs, attribute.String(attrHTTPErrorInternal, internal.Error()))
+	}
+
+	return attrs
+}
+
+// SkipCommittedErrorHandler wraps an HTTPErrorHandler so it does nothing once
+// the response has been committed. Use it for custom error handlers together
+// with HandleError, because Echo invokes the global error handler again when
+// the error is returned up the chain.
+func SkipCommittedErrorHandler(h echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(c *echo.Context, err error) {
+		if resp, _ := echo.UnwrapResponse(c.Response()); resp != nil && resp.Committed {
+			return
+		}
+
+		h(c, err)
+	}
+}
+
+// handleError forwards a handler error to Echo's global error handler if
+// HandleError is enabled, so the response it writes is captured on the span.
+func handleError(c *echo.Context, config OtelConfig, err error) {
+	if err == nil || !config.HandleError {
+		return
+	}
+
+	c.Echo().HTTPErrorHandler(c, err)
+}
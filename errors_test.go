@@ -0,0 +1,202 @@
+package echootelmiddleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGoTypeErrorClassifier(t *testing.T) {
+	assert.Equal(t, "*errors.errorString", GoTypeErrorClassifier(nil, http.StatusInternalServerError, errors.New("x")))
+	assert.Equal(t, "*echo.HTTPError", GoTypeErrorClassifier(nil, http.StatusNotFound, echo.NewHTTPError(http.StatusNotFound, "missing")))
+	assert.Equal(t, "503", GoTypeErrorClassifier(nil, http.StatusServiceUnavailable, nil))
+	assert.Equal(t, "", GoTypeErrorClassifier(nil, http.StatusNotFound, nil))
+	assert.Equal(t, "", GoTypeErrorClassifier(nil, http.StatusOK, nil))
+}
+
+func TestStatusClassErrorClassifier(t *testing.T) {
+	assert.Equal(t, "4xx", StatusClassErrorClassifier(nil, http.StatusBadRequest, errors.New("x")))
+	assert.Equal(t, "5xx", StatusClassErrorClassifier(nil, http.StatusBadGateway, nil))
+	assert.Equal(t, "_OTHER", StatusClassErrorClassifier(nil, 0, errors.New("x")))
+	assert.Equal(t, "", StatusClassErrorClassifier(nil, http.StatusNotFound, nil))
+}
+
+func TestHTTPErrorAttrs(t *testing.T) {
+	internal := errors.New("db timeout")
+	err := fmt.Errorf("handler: %w", echo.NewHTTPError(http.StatusBadGateway, "upstream failed").Wrap(internal))
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.Int("echo.http_error.code", http.StatusBadGateway),
+		attribute.String("echo.http_error.message", "upstream failed"),
+		attribute.String("echo.http_error.internal", "db timeout"),
+	}, httpErrorAttrs(err))
+
+	assert.Nil(t, httpErrorAttrs(errors.New("plain")))
+}
+
+func TestErrorTypeAttribute(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		classifier ErrorTypeClassifier
+		handler    echo.HandlerFunc
+		wantType   string
+	}{
+		{
+			name: "default go type",
+			handler: func(_ *echo.Context) error {
+				return echo.NewHTTPError(http.StatusBadRequest, "bad input")
+			},
+			wantType: "*echo.HTTPError",
+		},
+		{
+			name:       "status class",
+			classifier: StatusClassErrorClassifier,
+			handler: func(_ *echo.Context) error {
+				return echo.NewHTTPError(http.StatusBadRequest, "bad input")
+			},
+			wantType: "4xx",
+		},
+		{
+			name: "custom classifier",
+			classifier: func(_ *echo.Context, _ int, err error) string {
+				if err != nil {
+					return "validation"
+				}
+
+				return ""
+			},
+			handler: func(_ *echo.Context) error {
+				return echo.NewHTTPError(http.StatusBadRequest, "bad input")
+			},
+			wantType: "validation",
+		},
+		{
+			name: "no error",
+			handler: func(c *echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:      provider,
+				ErrorTypeClassifier: tc.classifier,
+			}))
+			router.GET("/x", tc.handler)
+
+			r := httptest.NewRequest(http.MethodGet, "/x", http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			attrs := spans[0].Attributes()
+
+			if tc.wantType == "" {
+				assert.False(t, hasAttrPrefix(attrs, "error.type"))
+				return
+			}
+
+			assert.Contains(t, attrs, attribute.String("error.type", tc.wantType))
+			assert.Contains(t, attrs, attribute.Int("echo.http_error.code", http.StatusBadRequest))
+			assert.Contains(t, attrs, attribute.String("echo.http_error.message", "bad input"))
+		})
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	errUnavailable := errors.New("backend unavailable")
+
+	for _, tc := range []struct {
+		name        string
+		handleError bool
+		wantStatus  int
+		wantCalls   int
+	}{
+		{
+			name:       "error handler runs after the span ends",
+			wantStatus: http.StatusInternalServerError,
+			wantCalls:  1,
+		},
+		{
+			name:        "error handler runs inside the span",
+			handleError: true,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantCalls:   1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			calls := 0
+
+			router := echo.New()
+			router.HTTPErrorHandler = SkipCommittedErrorHandler(func(c *echo.Context, err error) {
+				calls++
+
+				if errors.Is(err, errUnavailable) {
+					c.Response().Header().Set("Retry-After", "30")
+					_ = c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "try later"})
+
+					return
+				}
+
+				_ = c.NoContent(http.StatusInternalServerError)
+			})
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+				AreHeadersDump: true,
+				IsBodyDump:     true,
+				HandleError:    tc.handleError,
+			}))
+			router.GET("/", func(*echo.Context) error {
+				return errUnavailable
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Equal(t, tc.wantCalls, calls)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			attrs := spans[0].Attributes()
+
+			assert.Contains(t, attrs, attribute.Int("http.response.status_code", tc.wantStatus))
+
+			if tc.handleError {
+				assert.Contains(t, attrs, attribute.StringSlice("http.response.headers.retry_after", []string{"30"}))
+				assert.Contains(t, attrs, attribute.String("http.response.body", `{"error":"try later"}`+"\n"))
+			} else {
+				assert.False(t, hasAttrPrefix(attrs, "http.response.headers.retry_after"))
+			}
+		})
+	}
+}
+
+func TestSkipCommittedErrorHandler(t *testing.T) {
+	calls := 0
+	h := SkipCommittedErrorHandler(func(c *echo.Context, _ error) {
+		calls++
+		_ = c.NoContent(http.StatusTeapot)
+	})
+
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", http.NoBody), httptest.NewRecorder())
+	h(c, errors.New("first"))
+	h(c, errors.New("second"))
+
+	assert.Equal(t, 1, calls)
+}
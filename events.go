@@ -0,0 +1,50 @@
+package echootelmiddleware
+
+import (
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Span event names recorded when RecordLifecycleEvents is enabled.
+const (
+	eventRequestBodyRead        = "request.body.read"
+	eventHandlerStart           = "handler.start"
+	eventResponseHeadersWritten = "response.headers.written"
+	eventHandlerEnd             = "handler.end"
+	eventResponseFlush          = "response.flush"
+)
+
+// Span event attribute keys.
+const (
+	eventAttrBytes     = "bytes"
+	eventAttrTruncated = "truncated"
+)
+
+// addEvent records a lifecycle event on the span if enabled.
+func addEvent(span oteltrace.Span, config OtelConfig, name string, attrs ...attribute.KeyValue) {
+	if !config.RecordLifecycleEvents {
+		return
+	}
+
+	span.AddEvent(name, oteltrace.WithAttributes(attrs...))
+}
+
+// watchResponseHeaders records an event when the response status and headers
+// are written, using the *echo.Response Before hook so it fires whether or
+// not a response dumper is installed.
+func watchResponseHeaders(c *echo.Context, config OtelConfig, span oteltrace.Span) {
+	if !config.RecordLifecycleEvents {
+		return
+	}
+
+	resp, err := echo.UnwrapResponse(c.Response())
+	if err != nil || resp == nil {
+		return
+	}
+
+	resp.Before(func() {
+		addEvent(span, config, eventResponseHeadersWritten, semconv.HTTPResponseStatusCode(resp.Status))
+	})
+}
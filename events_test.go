@@ -0,0 +1,98 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func eventNames(events []sdktrace.Event) []string {
+	names := make([]string, 0, len(events))
+	for _, ev := range events {
+		names = append(names, ev.Name)
+	}
+
+	return names
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		bodyDump   bool
+		wantEvents []string
+	}{
+		{
+			name:       "without body dump",
+			wantEvents: []string{"handler.start", "response.headers.written", "handler.end"},
+		},
+		{
+			name:       "with body dump",
+			bodyDump:   true,
+			wantEvents: []string{"request.body.read", "handler.start", "response.headers.written", "handler.end"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:        provider,
+				IsBodyDump:            tc.bodyDump,
+				RecordLifecycleEvents: true,
+			}))
+			router.POST("/x", func(c *echo.Context) error {
+				return c.String(http.StatusCreated, "created")
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("hello"))
+			r.Header.Set(echo.HeaderContentType, "text/plain")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			events := spans[0].Events()
+			require.Equal(t, tc.wantEvents, eventNames(events))
+
+			byName := make(map[string][]attribute.KeyValue, len(events))
+			for _, ev := range events {
+				byName[ev.Name] = ev.Attributes
+			}
+
+			assert.Contains(t, byName["response.headers.written"], attribute.Int(statusTag, http.StatusCreated))
+			assert.Contains(t, byName["handler.end"], attribute.Int64("bytes", int64(len("created"))))
+
+			if tc.bodyDump {
+				assert.Contains(t, byName["request.body.read"], attribute.Int("bytes", len("hello")))
+				assert.Contains(t, byName["request.body.read"], attribute.Bool("truncated", false))
+			}
+		})
+	}
+}
+
+func TestLifecycleEventsDisabledByDefault(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{TracerProvider: provider, IsBodyDump: true}))
+	router.POST("/x", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("hello"))
+	r.Header.Set(echo.HeaderContentType, "text/plain")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Empty(t, sr.Ended()[0].Events())
+}
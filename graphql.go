@@ -0,0 +1,247 @@
+package echootelmiddleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	graphQLKey = "echo-otel-middleware-graphql"
+
+	attrGraphQLDocumentHash = "graphql.document.hash"
+	mimeGraphQL             = "application/graphql"
+)
+
+// graphQLOperation is the operation selected by a GraphQL request.
+type graphQLOperation struct {
+	opType string
+	name   string
+	hash   string
+}
+
+// attrs returns the graphql.* attributes of the operation.
+func (op graphQLOperation) attrs() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.GraphqlOperationTypeKey.String(op.opType),
+		attribute.String(attrGraphQLDocumentHash, op.hash),
+	}
+
+	if op.name != "" {
+		attrs = append(attrs, semconv.GraphqlOperationName(op.name))
+	}
+
+	return attrs
+}
+
+// graphQLRequest returns the document and operation name of a GraphQL
+// request: a JSON body with "query" and "operationName", an
+// application/graphql body, or a GET request with query parameters.
+func graphQLRequest(request *http.Request, config OtelConfig) (document, operationName string, ok bool) {
+	query := request.URL.Query()
+
+	if request.Method == http.MethodGet {
+		document = query.Get("query")
+
+		return document, query.Get("operationName"), document != ""
+	}
+
+	if request.Body == nil {
+		return "", "", false
+	}
+
+	buf, truncated, err := bufferRequestBody(request, config.MaxBodyDumpSize)
+	if err != nil {
+		return "", "", false
+	}
+
+	if enc := contentEncoding(request.Header.Get(headerContentEncoding)); enc != "" {
+		if buf, truncated, ok = decodeBody(enc, buf, truncated, config.MaxBodyDumpSize); !ok {
+			return "", "", false
+		}
+	}
+
+	if truncated {
+		return "", "", false
+	}
+
+	if mediaType(request.Header.Get(echo.HeaderContentType)) == mimeGraphQL {
+		return string(buf), query.Get("operationName"), len(buf) > 0
+	}
+
+	var params struct {
+		Query         string `json:"query"`
+		OperationName string `json:"operationName"`
+	}
+
+	if json.Unmarshal(buf, &params) != nil || params.Query == "" {
+		return "", "", false
+	}
+
+	return params.Query, params.OperationName, true
+}
+
+// parseGraphQLOperation finds the operation to execute in document: the one
+// called operationName, or the first operation when no name is given. The
+// document hash is computed over the normalized token stream, so whitespace,
+// commas and comments do not change it.
+func parseGraphQLOperation(document, operationName string) (graphQLOperation, bool) {
+	tokens := graphQLTokens(document)
+
+	var (
+		op    graphQLOperation
+		found bool
+		depth int
+	)
+
+	for i, tok := range tokens {
+		switch tok {
+		case "{":
+			if depth == 0 && !found && operationName == "" && (i == 0 || tokens[i-1] == "}") {
+				// Anonymous query shorthand.
+				op, found = graphQLOperation{opType: "query"}, true
+			}
+
+			depth++
+		case "}":
+			depth--
+		case "query", "mutation", "subscription":
+			if depth != 0 || found || (i > 0 && tokens[i-1] != "}") {
+				continue
+			}
+
+			var name string
+			if i+1 < len(tokens) && isGraphQLName(tokens[i+1]) {
+				name = tokens[i+1]
+			}
+
+			if operationName == "" || name == operationName {
+				op, found = graphQLOperation{opType: tok, name: name}, true
+			}
+		}
+	}
+
+	if !found {
+		return graphQLOperation{}, false
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(tokens, " ")))
+	op.hash = hex.EncodeToString(sum[:])
+
+	return op, true
+}
+
+// graphQLTokens splits a GraphQL document into tokens, dropping ignored
+// tokens (whitespace, commas and comments).
+func graphQLTokens(doc string) []string {
+	var tokens []string
+
+	for i := 0; i < len(doc); {
+		ch := doc[i]
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+		case ch == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := strings.Index(doc[i+3:], `"""`)
+			if end < 0 {
+				end = len(doc) - i - 3
+			} else {
+				end += 3
+			}
+
+			tokens = append(tokens, doc[i:i+3+end])
+			i += 3 + end
+		case ch == '"':
+			j := i + 1
+			for j < len(doc) && doc[j] != '"' && doc[j] != '\n' {
+				if doc[j] == '\\' {
+					j++
+				}
+
+				j++
+			}
+
+			j = min(j+1, len(doc))
+			tokens = append(tokens, doc[i:j])
+			i = j
+		case strings.HasPrefix(doc[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case isGraphQLNameStart(ch):
+			j := i + 1
+			for j < len(doc) && (isGraphQLNameStart(doc[j]) || isDigit(doc[j])) {
+				j++
+			}
+
+			tokens = append(tokens, doc[i:j])
+			i = j
+		case ch == '-' || isDigit(ch):
+			j := i + 1
+			for j < len(doc) && (isDigit(doc[j]) || strings.IndexByte(".eE+-", doc[j]) >= 0) {
+				j++
+			}
+
+			tokens = append(tokens, doc[i:j])
+			i = j
+		default:
+			tokens = append(tokens, doc[i:i+1])
+			i++
+		}
+	}
+
+	return tokens
+}
+
+func isGraphQLNameStart(ch byte) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isGraphQLName(tok string) bool {
+	return tok != "" && isGraphQLNameStart(tok[0])
+}
+
+// GraphQLOperationFromContext returns the type ("query", "mutation" or
+// "subscription") and name of the GraphQL operation recorded with IsGraphQL,
+// e.g. for a custom SpanNameFormatter. name is "" for anonymous operations.
+func GraphQLOperationFromContext(c *echo.Context) (opType, name string, ok bool) {
+	op, ok := c.Get(graphQLKey).(graphQLOperation)
+	if !ok {
+		return "", "", false
+	}
+
+	return op.opType, op.name, true
+}
+
+// extractGraphQL records the GraphQL operation of the request and renames the
+// span through SpanNameFormatter.
+func extractGraphQL(c *echo.Context, config OtelConfig, span oteltrace.Span) {
+	document, operationName, ok := graphQLRequest(c.Request(), config)
+	if !ok {
+		return
+	}
+
+	op, ok := parseGraphQLOperation(document, operationName)
+	if !ok {
+		return
+	}
+
+	c.Set(graphQLKey, op)
+	setAttr(span, config, op.attrs()...)
+	span.SetName(config.SpanNameFormatter(c, routeTemplate(c)))
+}
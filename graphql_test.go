@@ -0,0 +1,236 @@
+package echootelmiddleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGraphQLMiddleware(t *testing.T) {
+	const query = `query GetUser($id: ID!) { user(id: $id) { name } }`
+
+	for _, tc := range []struct {
+		name     string
+		config   OtelConfig
+		method   string
+		target   string
+		ct       string
+		body     string
+		wantName string
+		want     []attribute.KeyValue
+		noOp     bool
+	}{
+		{
+			name:     "json body without body dump",
+			method:   http.MethodPost,
+			target:   "/graphql",
+			ct:       echo.MIMEApplicationJSON,
+			body:     `{"query":"` + query + `","variables":{"id":"1"}}`,
+			wantName: "query GetUser",
+			want: []attribute.KeyValue{
+				attribute.String("graphql.operation.type", "query"),
+				attribute.String("graphql.operation.name", "GetUser"),
+			},
+		},
+		{
+			name:     "operation name selects the operation",
+			config:   OtelConfig{IsBodyDump: true},
+			method:   http.MethodPost,
+			target:   "/graphql",
+			ct:       echo.MIMEApplicationJSON,
+			body:     `{"query":"query A { a } mutation B { b }","operationName":"B"}`,
+			wantName: "mutation B",
+			want: []attribute.KeyValue{
+				attribute.String("graphql.operation.type", "mutation"),
+				attribute.String("graphql.operation.name", "B"),
+			},
+		},
+		{
+			name:     "application/graphql body",
+			method:   http.MethodPost,
+			target:   "/graphql",
+			ct:       mimeGraphQL,
+			body:     "subscription OnEvent { event }",
+			wantName: "subscription OnEvent",
+			want:     []attribute.KeyValue{attribute.String("graphql.operation.type", "subscription")},
+		},
+		{
+			name:     "get request",
+			method:   http.MethodGet,
+			target:   "/graphql?query=" + url.QueryEscape(query),
+			wantName: "query GetUser",
+			want:     []attribute.KeyValue{attribute.String("graphql.operation.name", "GetUser")},
+		},
+		{
+			name:     "anonymous query keeps the http name",
+			method:   http.MethodPost,
+			target:   "/graphql",
+			ct:       echo.MIMEApplicationJSON,
+			body:     `{"query":"{ me { name } }"}`,
+			wantName: "POST /graphql",
+			want:     []attribute.KeyValue{attribute.String("graphql.operation.type", "query")},
+		},
+		{
+			name:     "not graphql",
+			method:   http.MethodPost,
+			target:   "/graphql",
+			ct:       echo.MIMEApplicationJSON,
+			body:     `{"hello":"world"}`,
+			wantName: "POST /graphql",
+			noOp:     true,
+		},
+		{
+			name:     "truncated body",
+			config:   OtelConfig{MaxBodyDumpSize: 10},
+			method:   http.MethodPost,
+			target:   "/graphql",
+			ct:       echo.MIMEApplicationJSON,
+			body:     `{"query":"` + query + `"}`,
+			wantName: "POST /graphql",
+			noOp:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			tc.config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+			tc.config.IsGraphQL = true
+
+			var received string
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(tc.config))
+			router.Any("/graphql", func(c *echo.Context) error {
+				b, err := io.ReadAll(c.Request().Body)
+				received = string(b)
+
+				if err != nil {
+					return err
+				}
+
+				return c.NoContent(http.StatusOK)
+			})
+
+			r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.ct != "" {
+				r.Header.Set(echo.HeaderContentType, tc.ct)
+			}
+
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			// The handler still sees the full body.
+			assert.Equal(t, tc.body, received)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tc.wantName, spans[0].Name())
+
+			attrs := spans[0].Attributes()
+			for _, want := range tc.want {
+				assert.Contains(t, attrs, want)
+			}
+
+			assert.Equal(t, !tc.noOp, hasAttrPrefix(attrs, "graphql.document.hash"))
+		})
+	}
+}
+
+func TestGraphQLDisabledByDefault(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+	}))
+	router.POST("/graphql", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"query Q { a }"}`))
+	r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "POST /graphql", spans[0].Name())
+	assert.False(t, hasAttrPrefix(spans[0].Attributes(), "graphql."))
+}
+
+func TestGraphQLOperationFromContext(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	var (
+		gotType, gotName string
+		gotOK            bool
+	)
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsGraphQL:      true,
+		SpanNameFormatter: func(c *echo.Context, route string) string {
+			if opType, name, ok := GraphQLOperationFromContext(c); ok {
+				return "graphql " + opType + " " + name
+			}
+
+			return DefaultSpanNameFormatter(c, route)
+		},
+	}))
+	router.POST("/graphql", func(c *echo.Context) error {
+		gotType, gotName, gotOK = GraphQLOperationFromContext(c)
+
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"mutation AddUser { add }"}`))
+	r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	require.True(t, gotOK)
+	assert.Equal(t, "mutation", gotType)
+	assert.Equal(t, "AddUser", gotName)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "graphql mutation AddUser", spans[0].Name())
+
+	_, _, ok := GraphQLOperationFromContext(echo.New().NewContext(r, httptest.NewRecorder()))
+	assert.False(t, ok)
+}
+
+func TestParseGraphQLOperation(t *testing.T) {
+	op, ok := parseGraphQLOperation(`
+		# fetch a user
+		query GetUser($id: ID!, $query: String = "a, b") {
+			user(id: $id) { name ...UserFields }
+		}
+		fragment UserFields on User { email }
+	`, "")
+	require.True(t, ok)
+	assert.Equal(t, "query", op.opType)
+	assert.Equal(t, "GetUser", op.name)
+
+	// Whitespace, commas and comments do not change the hash.
+	same, ok := parseGraphQLOperation(`query GetUser($id: ID! $query: String = "a, b") { user(id: $id) { name ... UserFields } } fragment UserFields on User { email }`, "")
+	require.True(t, ok)
+	assert.Equal(t, op.hash, same.hash)
+
+	other, ok := parseGraphQLOperation(`query GetUser($id: ID!) { user(id: $id) { email } }`, "")
+	require.True(t, ok)
+	assert.NotEqual(t, op.hash, other.hash)
+
+	_, ok = parseGraphQLOperation(`query A { a }`, "Missing")
+	assert.False(t, ok)
+
+	_, ok = parseGraphQLOperation(`fragment F on User { name }`, "")
+	assert.False(t, ok)
+}
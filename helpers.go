@@ -1,11 +1,16 @@
 package echootelmiddleware
 
 import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/labstack/echo/v5"
 	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -116,21 +121,26 @@ func setAttr(span trace.Span, config OtelConfig, attrs ...attribute.KeyValue) {
 
 // prepareAttrs prepares attribute keys and values according to the given config.
 //
-// It first limits the attribute keys to the given size (in bytes), and then
-// limits the attribute values to the given size (also in bytes). If the given
-// size is 0 or less, the original attribute keys and values will be returned.
-// If removeNewLine is true, all newlines will be removed from the attribute
-// values.
+// String and string slice values are first passed through the ValueScrubber,
+// if any, using the full attribute key. It then limits the attribute keys to
+// the given size (in bytes), and then limits the attribute values to the given
+// size (also in bytes). If the given size is 0 or less, the original attribute
+// keys and values will be returned. If removeNewLine is true, all newlines
+// will be removed from the attribute values.
 //
 // Note that the given size is in bytes, not runes. This means that if the
 // attribute keys or values contain non-ASCII characters, the resulting
 // attribute keys or values may be shorter than the given size.
 func prepareAttrs(config OtelConfig, attrs ...attribute.KeyValue) []attribute.KeyValue {
-	if config.LimitNameSize <= 0 && config.LimitValueSize <= 0 && !config.RemoveNewLines {
+	if config.LimitNameSize <= 0 && config.LimitValueSize <= 0 && !config.RemoveNewLines && config.ValueScrubber == nil {
 		return attrs
 	}
 
 	for i := range attrs {
+		if config.ValueScrubber != nil {
+			attrs[i].Value = scrubValue(config.ValueScrubber, string(attrs[i].Key), attrs[i].Value)
+		}
+
 		if config.LimitNameSize > 0 {
 			attrs[i].Key = attribute.Key(prepareTagName(string(attrs[i].Key), config.LimitNameSize))
 		}
@@ -143,6 +153,66 @@ func prepareAttrs(config OtelConfig, attrs ...attribute.KeyValue) []attribute.Ke
 	return attrs
 }
 
+// scrubValue runs the scrubber over a string or string slice attribute value.
+// Other value types are returned unchanged.
+func scrubValue(scrub ValueScrubber, key string, v attribute.Value) attribute.Value {
+	switch v.Type() {
+	case attribute.STRING:
+		return attribute.StringValue(scrub(key, v.AsString()))
+	case attribute.STRINGSLICE:
+		values := v.AsStringSlice()
+		for i := range values {
+			values[i] = scrub(key, values[i])
+		}
+
+		return attribute.StringSliceValue(values)
+	default:
+		return v
+	}
+}
+
+// scrubString runs the ValueScrubber, if any, over a value recorded outside
+// of span attributes, such as an exception message or the status description.
+func scrubString(config OtelConfig, key, value string) string {
+	if config.ValueScrubber == nil {
+		return value
+	}
+
+	return config.ValueScrubber(key, value)
+}
+
+// recordError records err as an exception event like span.RecordError, with
+// the exception message passed through the ValueScrubber.
+func recordError(span trace.Span, config OtelConfig, err error, opts ...trace.EventOption) {
+	if config.ValueScrubber == nil {
+		span.RecordError(err, opts...)
+
+		return
+	}
+
+	opts = append(opts, trace.WithAttributes(
+		semconv.ExceptionType(errorTypeName(err)),
+		semconv.ExceptionMessage(scrubString(config, string(semconv.ExceptionMessageKey), err.Error())),
+	))
+
+	if cfg := trace.NewEventConfig(opts...); cfg.StackTrace() {
+		stack := make([]byte, 2048)
+		opts = append(opts, trace.WithAttributes(semconv.ExceptionStacktrace(string(stack[:runtime.Stack(stack, false)]))))
+	}
+
+	span.AddEvent(semconv.ExceptionEventName, opts...)
+}
+
+// errorTypeName formats the type of err like the SDK's RecordError.
+func errorTypeName(err error) string {
+	t := reflect.TypeOf(err)
+	if t.PkgPath() == "" && t.Name() == "" {
+		return t.String()
+	}
+
+	return t.PkgPath() + "." + t.Name()
+}
+
 // formatKey formats a header name as an attribute key: "{prefix}.{lowercase
 // name with - replaced by _}". Done in a single pass to avoid the intermediate
 // allocations of strings.ToLower + strings.ReplaceAll + concatenation.
@@ -168,6 +238,16 @@ func formatKey(k, prefix string) attribute.Key {
 	return attribute.Key(b.String())
 }
 
+// mediaType returns the lowercased media type of a Content-Type header value,
+// without parameters.
+func mediaType(ct string) string {
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+
+	return strings.TrimSpace(strings.ToLower(ct))
+}
+
 // isTextualContentType reports whether the given Content-Type header value
 // refers to a textual payload safe to attach to a span attribute.
 func isTextualContentType(ct string) bool {
@@ -175,11 +255,7 @@ func isTextualContentType(ct string) bool {
 		return false
 	}
 
-	if i := strings.IndexByte(ct, ';'); i >= 0 {
-		ct = ct[:i]
-	}
-
-	ct = strings.TrimSpace(strings.ToLower(ct))
+	ct = mediaType(ct)
 
 	if strings.HasPrefix(ct, "text/") {
 		return true
@@ -202,6 +278,35 @@ func isTextualContentType(ct string) bool {
 	return false
 }
 
+// canonicalHeaderList returns a copy of names in canonical MIME header form,
+// so they can be compared with http.Header keys.
+func canonicalHeaderList(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = http.CanonicalHeaderKey(name)
+	}
+
+	return out
+}
+
+// canonicalHeaderMap returns a copy of m keyed by canonical MIME header names.
+func canonicalHeaderMap(m map[string]attribute.Key) map[string]attribute.Key {
+	if len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[string]attribute.Key, len(m))
+	for name, key := range m {
+		out[http.CanonicalHeaderKey(name)] = key
+	}
+
+	return out
+}
+
 // defaultHeaderSkipper denies common authentication/cookie headers so
 // credentials are not sent to the tracing backend.
 func defaultHeaderSkipper(name string) bool {
@@ -217,6 +322,64 @@ func defaultHeaderSkipper(name string) bool {
 	return false
 }
 
+// defaultQueryParamSkipper redacts query parameters that commonly carry
+// credentials or signatures.
+func defaultQueryParamSkipper(name string) bool {
+	switch strings.ToLower(name) {
+	case "token",
+		"access_token",
+		"refresh_token",
+		"id_token",
+		"api_key",
+		"apikey",
+		"password",
+		"secret",
+		"client_secret",
+		"signature",
+		"sig":
+		return true
+	}
+
+	return false
+}
+
+// redactQuery replaces the values of skipped parameters in a raw query string
+// with "[redacted]". Parameter order and the encoding of other parameters are
+// preserved.
+func redactQuery(rawQuery string, skip QueryParamSkipper) string {
+	if skip == nil {
+		return rawQuery
+	}
+
+	var b strings.Builder
+
+	b.Grow(len(rawQuery))
+
+	for i, pair := range strings.Split(rawQuery, "&") {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+
+		key, _, hasValue := strings.Cut(pair, "=")
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		if !hasValue || !skip(name) {
+			b.WriteString(pair)
+			continue
+		}
+
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(valueRedacted)
+	}
+
+	return b.String()
+}
+
 // defaultBodySkipper skips request body capture for non-textual content types
 // (multipart, octet-stream, binary uploads). Response body capture is decided
 // after the handler runs based on the response Content-Type.
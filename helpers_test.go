@@ -242,3 +242,22 @@ func TestGetRequestID(t *testing.T) {
 		require.Equal(t, 32, len(getRequestID(c)))
 	})
 }
+
+func TestRedactQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "no sensitive params", query: "a=1&b=two", want: "a=1&b=two"},
+		{name: "token redacted", query: "q=go&token=abc&page=2", want: "q=go&token=[redacted]&page=2"},
+		{name: "case insensitive", query: "API_KEY=xyz", want: "API_KEY=[redacted]"},
+		{name: "escaped key", query: "access%5Ftoken=xyz", want: "access%5Ftoken=[redacted]"},
+		{name: "key without value kept", query: "token&x=1", want: "token&x=1"},
+		{name: "repeated params", query: "token=a&token=b", want: "token=[redacted]&token=[redacted]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, redactQuery(tc.query, defaultQueryParamSkipper))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package echootelmiddleware
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// LinkExtractor returns additional span links for the request, e.g. from a
+// secondary propagation format or a correlation header.
+type LinkExtractor func(c *echo.Context) []oteltrace.Link
+
+// PropagatorLinkExtractor returns a LinkExtractor that extracts a span context
+// from the request headers with the given propagator (e.g. an AWS X-Ray
+// propagator for X-Amzn-Trace-Id) and links it with the given attributes.
+func PropagatorLinkExtractor(p propagation.TextMapPropagator, attrs ...attribute.KeyValue) LinkExtractor {
+	return CarrierLinkExtractor(p, func(c *echo.Context) propagation.TextMapCarrier {
+		return propagation.HeaderCarrier(c.Request().Header)
+	}, attrs...)
+}
+
+// CarrierLinkExtractor is like PropagatorLinkExtractor but reads from the
+// carrier returned by carrier, e.g. to map a queue's "X-Origin-Traceparent"
+// header onto "traceparent".
+func CarrierLinkExtractor(p propagation.TextMapPropagator, carrier func(c *echo.Context) propagation.TextMapCarrier, attrs ...attribute.KeyValue) LinkExtractor {
+	return func(c *echo.Context) []oteltrace.Link {
+		sc := oteltrace.SpanContextFromContext(p.Extract(context.Background(), carrier(c)))
+		if !sc.IsValid() {
+			return nil
+		}
+
+		return []oteltrace.Link{{SpanContext: sc, Attributes: attrs}}
+	}
+}
+
+// HeaderLinkExtractor returns a LinkExtractor for correlation headers that
+// carry no span context (e.g. X-Correlation-ID). It adds a link without a span
+// context whose key attribute holds the header value.
+func HeaderLinkExtractor(header string, key attribute.Key) LinkExtractor {
+	return func(c *echo.Context) []oteltrace.Link {
+		value := c.Request().Header.Get(header)
+		if value == "" {
+			return nil
+		}
+
+		return []oteltrace.Link{{Attributes: []attribute.KeyValue{key.String(value)}}}
+	}
+}
+
+// extractLinks runs the configured LinkExtractors and applies the attribute
+// limits to the link attributes.
+func extractLinks(c *echo.Context, config OtelConfig) []oteltrace.Link {
+	var links []oteltrace.Link
+
+	for _, extract := range config.LinkExtractors {
+		for _, link := range extract(c) {
+			link.Attributes = prepareAttrs(config, append([]attribute.KeyValue(nil), link.Attributes...)...)
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
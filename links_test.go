@@ -0,0 +1,81 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLinkExtractors(t *testing.T) {
+	const (
+		queueTraceID     = "0af7651916cd43dd8448eb211c80319c"
+		queueSpanID      = "b7ad6b7169203331"
+		queueTraceparent = "00-" + queueTraceID + "-" + queueSpanID + "-01"
+	)
+
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		Propagator:     propagation.TraceContext{},
+		LimitValueSize: 8,
+		LinkExtractors: []LinkExtractor{
+			CarrierLinkExtractor(propagation.TraceContext{}, func(c *echo.Context) propagation.TextMapCarrier {
+				return propagation.MapCarrier{"traceparent": c.Request().Header.Get("X-Origin-Traceparent")}
+			}, attribute.String("link.source", "queue")),
+			HeaderLinkExtractor("X-Correlation-ID", "correlation.id"),
+			// Nothing to extract: no link is added.
+			PropagatorLinkExtractor(propagation.Baggage{}),
+		},
+	}))
+	router.GET("/", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Traceparent", remoteTraceparent)
+	r.Header.Set("X-Origin-Traceparent", queueTraceparent)
+	r.Header.Set("X-Correlation-ID", "corr-1234567890")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+
+	// The primary propagator still provides the parent.
+	assert.Equal(t, remoteSpanID, span.Parent().SpanID().String())
+
+	links := span.Links()
+	require.Len(t, links, 2)
+
+	assert.Equal(t, queueTraceID, links[0].SpanContext.TraceID().String())
+	assert.Equal(t, queueSpanID, links[0].SpanContext.SpanID().String())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("link.source", "queue")}, links[0].Attributes)
+
+	assert.False(t, links[1].SpanContext.IsValid())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("correlation.id", "corr-123")}, links[1].Attributes)
+}
+
+func TestPropagatorLinkExtractor(t *testing.T) {
+	extract := PropagatorLinkExtractor(propagation.TraceContext{}, attribute.Bool("secondary", true))
+	e := echo.New()
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	assert.Empty(t, extract(e.NewContext(r, httptest.NewRecorder())))
+
+	r.Header.Set("Traceparent", remoteTraceparent)
+	links := extract(e.NewContext(r, httptest.NewRecorder()))
+	require.Len(t, links, 1)
+	assert.Equal(t, remoteTraceID, links[0].SpanContext.TraceID().String())
+	assert.Equal(t, []attribute.KeyValue{attribute.Bool("secondary", true)}, links[0].Attributes)
+}
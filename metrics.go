@@ -0,0 +1,176 @@
+package echootelmiddleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// durationBuckets are the explicit bucket boundaries (in seconds) recommended
+// by the semantic conventions for http.server.request.duration.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// serverMetrics holds the semconv HTTP server instruments. Instruments that
+// fail to register are left as no-ops so a misconfigured MeterProvider never
+// breaks request handling.
+type serverMetrics struct {
+	duration       metric.Float64Histogram
+	activeRequests metric.Int64UpDownCounter
+	requestSize    metric.Int64Histogram
+	responseSize   metric.Int64Histogram
+}
+
+// newServerMetrics creates the HTTP server instruments from the given provider.
+// Registration errors are reported to the global OpenTelemetry error handler.
+func newServerMetrics(mp metric.MeterProvider) *serverMetrics {
+	meter := mp.Meter(tracerName)
+
+	duration, err := meter.Float64Histogram(
+		semconv.HTTPServerRequestDurationName,
+		metric.WithUnit(semconv.HTTPServerRequestDurationUnit),
+		metric.WithDescription(semconv.HTTPServerRequestDurationDescription),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	handleMetricErr(err)
+
+	activeRequests, err := meter.Int64UpDownCounter(
+		semconv.HTTPServerActiveRequestsName,
+		metric.WithUnit(semconv.HTTPServerActiveRequestsUnit),
+		metric.WithDescription(semconv.HTTPServerActiveRequestsDescription),
+	)
+	handleMetricErr(err)
+
+	requestSize, err := meter.Int64Histogram(
+		semconv.HTTPServerRequestBodySizeName,
+		metric.WithUnit(semconv.HTTPServerRequestBodySizeUnit),
+		metric.WithDescription(semconv.HTTPServerRequestBodySizeDescription),
+	)
+	handleMetricErr(err)
+
+	responseSize, err := meter.Int64Histogram(
+		semconv.HTTPServerResponseBodySizeName,
+		metric.WithUnit(semconv.HTTPServerResponseBodySizeUnit),
+		metric.WithDescription(semconv.HTTPServerResponseBodySizeDescription),
+	)
+	handleMetricErr(err)
+
+	m := &serverMetrics{
+		duration:       duration,
+		activeRequests: activeRequests,
+		requestSize:    requestSize,
+		responseSize:   responseSize,
+	}
+
+	if m.duration == nil {
+		m.duration = noop.Float64Histogram{}
+	}
+
+	if m.activeRequests == nil {
+		m.activeRequests = noop.Int64UpDownCounter{}
+	}
+
+	if m.requestSize == nil {
+		m.requestSize = noop.Int64Histogram{}
+	}
+
+	if m.responseSize == nil {
+		m.responseSize = noop.Int64Histogram{}
+	}
+
+	return m
+}
+
+// handleMetricErr forwards instrument registration errors to the global
+// OpenTelemetry error handler.
+func handleMetricErr(err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// activeRequestAttrs returns the attributes for http.server.active_requests.
+// Only attributes known before the handler runs are used, so the increment
+// and decrement always land on the same series.
+func activeRequestAttrs(request *http.Request) attribute.Set {
+	return attribute.NewSet(
+		semconv.HTTPRequestMethodKey.String(request.Method),
+		semconv.URLScheme(request.URL.Scheme),
+	)
+}
+
+// requestMetricAttrs returns the attributes shared by the duration and body
+// size histograms: method, scheme, protocol, route, response status and error
+// type.
+func requestMetricAttrs(request *http.Request, route string, status int, errType string) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, 7)
+	attrs = append(attrs,
+		semconv.HTTPRequestMethodKey.String(request.Method),
+		semconv.URLScheme(request.URL.Scheme),
+	)
+
+	if name, version := splitProto(request.Proto); name != "" {
+		attrs = append(attrs, semconv.NetworkProtocolName(name))
+		if version != "" {
+			attrs = append(attrs, semconv.NetworkProtocolVersion(version))
+		}
+	}
+
+	if route != "" {
+		attrs = append(attrs, semconv.HTTPRoute(route))
+	}
+
+	if status > 0 {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(status))
+	}
+
+	if errType != "" {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errType))
+	}
+
+	return attribute.NewSet(attrs...)
+}
+
+// responseSize returns the number of body bytes written to the client, read
+// from the underlying *echo.Response.
+func responseSize(c *echo.Context) int64 {
+	resp, err := echo.UnwrapResponse(c.Response())
+	if err != nil || resp == nil {
+		return 0
+	}
+
+	return resp.Size
+}
+
+// startRequest increments the active request counter and returns a function
+// that decrements it again. Only the first call of the returned function
+// decrements, so it can both end an upgraded request early and be deferred.
+func (m *serverMetrics) startRequest(ctx context.Context, request *http.Request) func() {
+	opt := metric.WithAttributeSet(activeRequestAttrs(request))
+	m.activeRequests.Add(ctx, 1, opt)
+
+	return sync.OnceFunc(func() {
+		m.activeRequests.Add(ctx, -1, opt)
+	})
+}
+
+// endRequest records the duration and body size histograms for a finished
+// request.
+func (m *serverMetrics) endRequest(ctx context.Context, c *echo.Context, request *http.Request, start time.Time, status int, errType string) {
+	opt := metric.WithAttributeSet(requestMetricAttrs(request, routeTemplate(c), status, errType))
+
+	m.duration.Record(ctx, time.Since(start).Seconds(), opt)
+
+	if request.ContentLength >= 0 {
+		m.requestSize.Record(ctx, request.ContentLength, opt)
+	}
+
+	m.responseSize.Record(ctx, responseSize(c), opt)
+}
@@ -0,0 +1,189 @@
+package echootelmiddleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func collectMetrics(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Metrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	got := make(map[string]metricdata.Metrics)
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m
+		}
+	}
+
+	return got
+}
+
+func TestServerMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{MeterProvider: mp}))
+	router.POST(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusCreated, "created")
+	})
+
+	r := httptest.NewRequest(http.MethodPost, userURL, strings.NewReader("hello"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	got := collectMetrics(t, reader)
+
+	wantAttrs := attribute.NewSet(
+		attribute.String(methodTag, http.MethodPost),
+		attribute.String("url.scheme", ""),
+		attribute.String("network.protocol.name", "http"),
+		attribute.String("network.protocol.version", "1.1"),
+		attribute.String(routeTag, userEndpoint),
+		attribute.Int(statusTag, http.StatusCreated),
+	)
+
+	duration, ok := got["http.server.request.duration"].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, uint64(1), duration.DataPoints[0].Count)
+	assert.True(t, wantAttrs.Equals(&duration.DataPoints[0].Attributes))
+
+	reqSize, ok := got["http.server.request.body.size"].Data.(metricdata.Histogram[int64])
+	require.True(t, ok)
+	require.Len(t, reqSize.DataPoints, 1)
+	assert.Equal(t, int64(5), reqSize.DataPoints[0].Sum)
+
+	respSize, ok := got["http.server.response.body.size"].Data.(metricdata.Histogram[int64])
+	require.True(t, ok)
+	require.Len(t, respSize.DataPoints, 1)
+	assert.Equal(t, int64(len("created")), respSize.DataPoints[0].Sum)
+
+	active, ok := got["http.server.active_requests"].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, active.DataPoints, 1)
+	assert.Equal(t, int64(0), active.DataPoints[0].Value)
+}
+
+func TestServerMetricsActiveDuringRequest(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	var inFlight int64
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{MeterProvider: mp}))
+	router.GET("/ping", func(c *echo.Context) error {
+		active, ok := collectMetrics(t, reader)["http.server.active_requests"].Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, active.DataPoints, 1)
+		inFlight = active.DataPoints[0].Value
+
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/ping", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, int64(1), inFlight)
+}
+
+func TestServerMetricsErrorStatus(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{MeterProvider: mp}))
+	router.GET("/err", func(_ *echo.Context) error {
+		return errors.New("oh no")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/err", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	duration, ok := collectMetrics(t, reader)["http.server.request.duration"].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 1)
+
+	status, ok := duration.DataPoints[0].Attributes.Value(statusTag)
+	require.True(t, ok)
+	assert.Equal(t, int64(http.StatusInternalServerError), status.AsInt64())
+}
+
+func TestServerMetricsPanic(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{MeterProvider: mp}))
+	router.GET("/boom", func(_ *echo.Context) error {
+		panic("kaboom")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/boom", http.NoBody)
+	require.Panics(t, func() {
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	})
+
+	got := collectMetrics(t, reader)
+
+	duration, ok := got["http.server.request.duration"].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 1)
+
+	attrs := duration.DataPoints[0].Attributes
+	status, ok := attrs.Value(statusTag)
+	require.True(t, ok)
+	assert.Equal(t, int64(http.StatusInternalServerError), status.AsInt64())
+
+	errType, ok := attrs.Value("error.type")
+	require.True(t, ok)
+	assert.Equal(t, "*errors.errorString", errType.AsString())
+
+	active, ok := got["http.server.active_requests"].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, active.DataPoints, 1)
+	assert.Equal(t, int64(0), active.DataPoints[0].Value)
+}
+
+func TestServerMetricsNonRecordingSpan(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: noop.NewTracerProvider(),
+		MeterProvider:  mp,
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, userID)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, userURL, http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	duration, ok := collectMetrics(t, reader)["http.server.request.duration"].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, uint64(1), duration.DataPoints[0].Count)
+}
@@ -10,14 +10,20 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/adlandh/response-dumper"
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	logglobal "go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
@@ -34,6 +40,10 @@ type BodySkipper func(*echo.Context) (skipReqBody bool, skipRespBody bool)
 // attributes. The name is passed in its canonical (MIME) form.
 type HeaderSkipper func(name string) bool
 
+// QueryParamSkipper reports whether the value of the named query parameter
+// should be redacted from the url.query attribute.
+type QueryParamSkipper func(name string) bool
+
 type (
 	// OtelConfig defines the config for OpenTelemetry middleware.
 	OtelConfig struct {
@@ -43,49 +53,205 @@ type (
 		// BodySkipper defines a function to exclude body from logging
 		BodySkipper BodySkipper
 
+		// BodyRedactor masks sensitive data in captured bodies before they are
+		// attached to the span, e.g. FieldPathRedactor. Truncated bodies are
+		// passed through unchanged.
+		BodyRedactor BodyRedactor
+
 		// HeaderSkipper redacts sensitive headers from span attributes.
 		// The default denies Authorization, Cookie, Set-Cookie,
 		// Proxy-Authorization, and X-Api-Key.
 		HeaderSkipper HeaderSkipper
 
+		// QueryParamSkipper redacts sensitive query parameter values from
+		// url.query. The default redacts common token/secret parameters such
+		// as token, access_token, api_key, password and signature.
+		QueryParamSkipper QueryParamSkipper
+
+		// HeaderAllowlist, when non-empty, limits dumped headers to the listed
+		// names (case-insensitive); all other headers are omitted.
+		HeaderAllowlist []string
+
+		// HeaderAttributeNames maps request header names (case-insensitive)
+		// to custom attribute keys, e.g. "X-Tenant-ID" -> "tenant.id".
+		HeaderAttributeNames map[string]attribute.Key
+
+		// ResponseHeaderAttributeNames maps response header names
+		// (case-insensitive) to custom attribute keys. It is separate from
+		// HeaderAttributeNames so a header sent both ways keeps both values.
+		ResponseHeaderAttributeNames map[string]attribute.Key
+
+		// IsBaggageDump copies W3C baggage members from the extracted context
+		// into span attributes.
+		IsBaggageDump bool
+
+		// BaggageAllowlist, when non-empty, limits promoted baggage members to
+		// the listed keys (case-sensitive).
+		BaggageAllowlist []string
+
+		// BaggageAttributePrefix is prepended to baggage member keys. Default:
+		// "baggage.".
+		BaggageAttributePrefix string
+
+		// AttributeSchema selects the keys used for headers, path parameters,
+		// the request ID and bodies: LegacySchema (default) or SemconvSchema.
+		AttributeSchema AttributeSchema
+
+		// SpanNameFormatter builds the span name from the request and the
+		// normalized route. Default: DefaultSpanNameFormatter. See also
+		// GraphQLOperationFromContext and RPCCallFromContext.
+		SpanNameFormatter SpanNameFormatter
+
+		// StatusClassifier maps the response status and handler error to the
+		// span status. Default: LegacyStatusClassifier; see also
+		// SemconvServerStatusClassifier. With IsRPC, RPCStatusFromContext
+		// returns the gRPC or Connect status of the call.
+		StatusClassifier StatusClassifier
+
+		// ErrorTypeClassifier derives the error.type attribute (also used on
+		// metrics). Default: GoTypeErrorClassifier; see also
+		// StatusClassErrorClassifier.
+		ErrorTypeClassifier ErrorTypeClassifier
+
+		// RequestAttributes returns extra attributes (tenant ID, user ID, ...)
+		// added to the span before the handler runs. The request context
+		// already carries the span.
+		RequestAttributes func(c *echo.Context) []attribute.KeyValue
+
+		// ResponseAttributes returns extra attributes added to the span after
+		// the handler returns, with the resolved status and handler error.
+		ResponseAttributes func(c *echo.Context, status int, err error) []attribute.KeyValue
+
 		// OpenTelemetry TracerProvider
 		TracerProvider oteltrace.TracerProvider
 
+		// OpenTelemetry MeterProvider used for the HTTP server metrics
+		MeterProvider metric.MeterProvider
+
+		// OpenTelemetry LoggerProvider used by LogBodySink
+		LoggerProvider otellog.LoggerProvider
+
 		// OpenTelemetry Propagator
 		Propagator propagation.TextMapPropagator
 
+		// TraceResponseHeaders selects the trace context headers written on
+		// the response (traceresponse, Server-Timing). Default: none.
+		TraceResponseHeaders TraceResponseHeaders
+
+		// InboundContextSkipper defines a function to skip trusting the
+		// inbound trace context. For skipped requests the server span starts a
+		// new trace and links the remote span context instead of using it as
+		// the parent, so external callers cannot force sampling or choose
+		// trace IDs, and inbound baggage is dropped. Default: trust all
+		// requests.
+		InboundContextSkipper middleware.Skipper
+
+		// LinkExtractors add span links to the server span, e.g. for trace
+		// contexts sent in secondary formats or correlation headers. See
+		// PropagatorLinkExtractor, CarrierLinkExtractor and HeaderLinkExtractor.
+		LinkExtractors []LinkExtractor
+
+		// TraceUpgradedConnections starts a connection span after a protocol
+		// upgrade (e.g. WebSocket), ended when the hijacked connection is
+		// closed. Handlers can record messages and the close code with
+		// ConnectionMessageSent, ConnectionMessageReceived and
+		// ConnectionClosed.
+		TraceUpgradedConnections bool
+
+		// HandleError calls Echo's global HTTPErrorHandler when the handler
+		// returns an error, so the status, headers and body it writes are
+		// recorded on the span. The error is still returned; custom error
+		// handlers should ignore committed responses (see
+		// SkipCommittedErrorHandler).
+		HandleError bool
+
+		// TraceResponseSkipper defines a function to skip writing trace
+		// response headers, e.g. TrustedOriginsSkipper.
+		TraceResponseSkipper middleware.Skipper
+
 		// add req headers & resp headers to tracing tags
 		AreHeadersDump bool
 
 		// add req body & resp body to attributes
 		IsBodyDump bool
 
+		// BodySink selects where dumped bodies go: span attributes (default),
+		// span events or log records. With events and logs the span only
+		// holds the size and SHA-256 of each body.
+		BodySink BodySink
+
+		// RecordLifecycleEvents adds span events for request body read,
+		// handler start, response headers written and handler return.
+		RecordLifecycleEvents bool
+
+		// add url.path to attributes
+		IsURLPathDump bool
+
+		// add url.query to attributes (values redacted by QueryParamSkipper)
+		IsURLQueryDump bool
+
+		// BodyDumpOnErrorOnly still buffers bodies when IsBodyDump is enabled,
+		// but only attaches them to spans that end with an Error status or
+		// exceed BodyDumpLatencyThreshold.
+		BodyDumpOnErrorOnly bool
+
+		// BodyDumpLatencyThreshold also keeps bodies for requests at least this
+		// slow when BodyDumpOnErrorOnly is set. <=0 disables the latency check.
+		BodyDumpLatencyThreshold time.Duration
+
 		// remove \\n from values (necessary for sentry)
 		RemoveNewLines bool
 
+		// ValueScrubber masks secrets inside string attribute values (header
+		// values, bodies, ...), exception messages and the status
+		// description, e.g. NewValueScrubber(AllDetectors()).
+		ValueScrubber ValueScrubber
+
 		// Tag name limit size. <=0 for unlimited, for sentry use 32
 		LimitNameSize int
 
 		// Tag value limit size (in bytes). <=0 for unlimited, for sentry use 200
 		LimitValueSize int
 
+		// RouteOverrides adjusts the config for individual routes or route
+		// prefixes; the first matching override wins. Overrides are resolved
+		// from c.Path() when the middleware runs and share the metric
+		// instruments of this middleware. They never apply when the
+		// middleware is registered with e.Pre(), since routing has not run
+		// yet and c.Path() is empty. Route-specific features such as IsRPC,
+		// IsGraphQL and StreamingResponse are usually enabled here.
+		RouteOverrides []RouteOverride
+
+		// IsRPC records rpc.* attributes for gRPC, gRPC-Web and Connect calls.
+		IsRPC bool
+
+		// IsGraphQL records graphql.* attributes for GraphQL requests, buffering
+		// the body up to MaxBodyDumpSize even when IsBodyDump is off.
+		IsGraphQL bool
+
+		// StreamingResponse marks responses as streams (e.g. chunked downloads).
+		StreamingResponse bool
+
+		// DetectStreaming also treats text/event-stream or flushed responses as streams.
+		DetectStreaming bool
+
 		// MaxBodyDumpSize caps the number of bytes buffered from the request or
 		// response body when IsBodyDump is enabled. Set to <0 for unlimited
-		// (unsafe: a large upload can exhaust memory). Default is 64 KiB.
+		// (unsafe: a large upload can exhaust memory); streamed responses are
+		// still capped at 64 KiB. Default is 64 KiB.
 		MaxBodyDumpSize int64
 	}
 )
 
 const defaultMaxBodyDumpSize int64 = 64 * 1024
 
-// Attribute keys and marker values shared by request/response body dumps.
+// Marker values shared by request/response body and header dumps.
 const (
-	attrRequestBody  = "http.request.body"
-	attrResponseBody = "http.response.body"
-	bodyExcluded     = "[excluded]"
-	bodyReadError    = "[read-error]"
-	bodyTruncated    = "[truncated]"
-	bodyNonText      = "[non-text content]"
+	bodyExcluded  = "[excluded]"
+	bodyReadError = "[read-error]"
+	bodyTruncated = "[truncated]"
+	bodyNonText   = "[non-text content]"
+	valueRedacted = "[redacted]"
 )
 
 var (
@@ -104,13 +270,14 @@ func shouldSkipMiddleware(c *echo.Context, config OtelConfig) bool {
 }
 
 // processNextHandler calls the next handler and records any error on the span.
-// The error is returned unchanged so Echo's router invokes HTTPErrorHandler
-// exactly once.
+// With HandleError the error is passed to Echo's HTTPErrorHandler while the
+// span is still open. The error is returned unchanged either way.
 func processNextHandler(c *echo.Context, next echo.HandlerFunc, config OtelConfig, span oteltrace.Span) error {
 	err := next(c)
 	if err != nil {
-		span.RecordError(err)
-		setAttr(span, config, attribute.String("echo.error", err.Error()))
+		recordError(span, config, err)
+		setAttr(span, config, append(httpErrorAttrs(err), attribute.String("echo.error", err.Error()))...)
+		handleError(c, config, err)
 	}
 
 	return err
@@ -120,7 +287,7 @@ func processNextHandler(c *echo.Context, next echo.HandlerFunc, config OtelConfi
 // span in a single SetAttributes call.
 func addPathParameters(c *echo.Context, config OtelConfig, span oteltrace.Span) {
 	params := c.RouteInfo().Parameters
-	path := c.Path()
+	path := routeTemplate(c)
 
 	if path == "" && len(params) == 0 {
 		return
@@ -132,185 +299,324 @@ func addPathParameters(c *echo.Context, config OtelConfig, span oteltrace.Span)
 	}
 
 	for _, paramName := range params {
-		attrs = append(attrs, attribute.String("http.path."+paramName, c.Param(paramName)))
+		attrs = append(attrs, attribute.String(config.AttributeSchema.keys().pathParamPrefix+paramName, c.Param(paramName)))
 	}
 
 	setAttr(span, config, attrs...)
 }
 
-// teeReadCloser preserves the original body's Close while reading from a
-// MultiReader. Used when the request body exceeds MaxBodyDumpSize so the
-// handler can still consume the remaining bytes.
-type teeReadCloser struct {
+// dumpURL adds url.path and url.query to the span when enabled. Sensitive
+// query parameter values are replaced by QueryParamSkipper.
+func dumpURL(request *http.Request, config OtelConfig, span oteltrace.Span) {
+	attrs := make([]attribute.KeyValue, 0, 2)
+
+	if config.IsURLPathDump {
+		attrs = append(attrs, semconv.URLPath(request.URL.Path))
+	}
+
+	if config.IsURLQueryDump && request.URL.RawQuery != "" {
+		attrs = append(attrs, semconv.URLQuery(redactQuery(request.URL.RawQuery, config.QueryParamSkipper)))
+	}
+
+	if len(attrs) > 0 {
+		setAttr(span, config, attrs...)
+	}
+}
+
+// bufferedBody replaces a request body read by the middleware. It replays the
+// buffered bytes followed by the unread remainder, if any, and keeps the
+// buffered prefix so the body is only read once per request.
+type bufferedBody struct {
 	io.Reader
 	io.Closer
+
+	buf       []byte
+	truncated bool
+}
+
+// bufferRequestBody reads up to maxSize bytes of the request body (all of it
+// if maxSize <= 0) and resets the body so the handler still sees the full
+// payload. A body already buffered by the middleware is not read again.
+func bufferRequestBody(request *http.Request, maxSize int64) ([]byte, bool, error) {
+	if b, ok := request.Body.(*bufferedBody); ok {
+		return b.buf, b.truncated, nil
+	}
+
+	origBody := request.Body
+
+	if maxSize <= 0 {
+		buf, err := io.ReadAll(origBody)
+		if err != nil {
+			return nil, false, err
+		}
+
+		_ = origBody.Close()
+		request.Body = &bufferedBody{Reader: bytes.NewReader(buf), Closer: io.NopCloser(nil), buf: buf}
+
+		return buf, false, nil
+	}
+
+	// Read one extra byte to detect truncation.
+	buf, err := io.ReadAll(io.LimitReader(origBody, maxSize+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if int64(len(buf)) <= maxSize {
+		_ = origBody.Close()
+		request.Body = &bufferedBody{Reader: bytes.NewReader(buf), Closer: io.NopCloser(nil), buf: buf}
+
+		return buf, false, nil
+	}
+
+	// Hand the handler a stream of: already-read bytes + remaining body,
+	// preserving the original body's Close.
+	request.Body = &bufferedBody{
+		Reader:    io.MultiReader(bytes.NewReader(buf), origBody),
+		Closer:    origBody,
+		buf:       buf[:maxSize],
+		truncated: true,
+	}
+
+	return buf[:maxSize], true, nil
+}
+
+// capturedBody is a dumped body attribute and the number of body bytes
+// captured for it, before decoding.
+type capturedBody struct {
+	attr attribute.KeyValue
+	size int
 }
 
 // dumpRequestBody reads (up to MaxBodyDumpSize bytes of) the request body and
-// attaches it to the span. The original body is reset so the handler still
-// sees the full payload.
-func dumpRequestBody(request *http.Request, config OtelConfig, span oteltrace.Span, skipReqBody bool) {
+// returns it as an attribute; the zero value is returned when there is no
+// body. The original body is reset so the handler still sees the full payload.
+func dumpRequestBody(request *http.Request, config OtelConfig, span oteltrace.Span, skipReqBody bool) capturedBody {
 	if request.Body == nil {
-		return
+		return capturedBody{}
 	}
 
 	if skipReqBody {
-		setAttr(span, config, attribute.String(attrRequestBody, bodyExcluded))
-		return
+		return capturedBody{attr: attribute.String(config.AttributeSchema.keys().requestBody, bodyExcluded)}
 	}
 
-	origBody := request.Body
-	maxSize := config.MaxBodyDumpSize
+	buf, truncated, err := bufferRequestBody(request, config.MaxBodyDumpSize)
+	if err != nil {
+		recordError(span, config, err)
 
-	var (
-		buf       []byte
-		err       error
-		truncated bool
+		return capturedBody{attr: attribute.String(config.AttributeSchema.keys().requestBody, bodyReadError)}
+	}
+
+	return capturedBody{attr: requestBodyAttr(request, config, span, buf, truncated), size: len(buf)}
+}
+
+// requestBodyAttr returns the captured request body as an attribute, decoded
+// and redacted.
+func requestBodyAttr(request *http.Request, config OtelConfig, span oteltrace.Span, buf []byte, truncated bool) attribute.KeyValue {
+	addEvent(span, config, eventRequestBodyRead,
+		attribute.Int(eventAttrBytes, len(buf)),
+		attribute.Bool(eventAttrTruncated, truncated),
 	)
 
-	if maxSize > 0 {
-		// Read one extra byte to detect truncation.
-		buf, err = io.ReadAll(io.LimitReader(origBody, maxSize+1))
-		if err == nil && int64(len(buf)) > maxSize {
-			truncated = true
-			dumped := buf[:maxSize]
-			// Hand the handler a stream of: already-read bytes + remaining body.
-			request.Body = teeReadCloser{
-				Reader: io.MultiReader(bytes.NewReader(buf), origBody),
-				Closer: origBody,
-			}
-			buf = dumped
-		} else if err == nil {
-			_ = origBody.Close()
-			request.Body = io.NopCloser(bytes.NewReader(buf))
+	// Decompress the captured copy only
+	if enc := contentEncoding(request.Header.Get(headerContentEncoding)); enc != "" {
+		decoded, decodedTruncated, ok := decodeBody(enc, buf, truncated, config.MaxBodyDumpSize)
+		if !ok {
+			return attribute.String(config.AttributeSchema.keys().requestBody, bodyNonText)
 		}
-	} else {
-		buf, err = io.ReadAll(origBody)
-		if err == nil {
-			_ = origBody.Close()
-			request.Body = io.NopCloser(bytes.NewReader(buf))
-		}
-	}
 
-	if err != nil {
-		span.RecordError(err)
-		setAttr(span, config, attribute.String(attrRequestBody, bodyReadError))
+		buf, truncated = decoded, decodedTruncated
+	}
 
-		return
+	if codec, ok := requestRPCCodec(request); ok && config.IsRPC {
+		if body, ok := codec.bodyText(buf, truncated, config.BodyRedactor); ok {
+			return attribute.String(config.AttributeSchema.keys().requestBody, body)
+		}
 	}
 
 	body := strings.ToValidUTF8(string(buf), "")
 	if truncated {
 		body += bodyTruncated
+	} else if config.BodyRedactor != nil {
+		body = config.BodyRedactor(request.Header.Get(echo.HeaderContentType), body)
 	}
 
-	setAttr(span, config, attribute.String(attrRequestBody, body))
+	return attribute.String(config.AttributeSchema.keys().requestBody, body)
 }
 
 // setupResponseDumper creates and sets up a response dumper.
-func setupResponseDumper(c *echo.Context, maxBodyDumpSize int64) *response.Dumper {
+func setupResponseDumper(c *echo.Context, maxBodyDumpSize int64) *streamDumper {
 	maxBytes := 0
 	if maxBodyDumpSize > 0 {
 		maxBytes = int(min(maxBodyDumpSize, math.MaxInt))
 	}
 
-	respDumper := response.NewDumper(c.Response(), response.WithMaxBytes(maxBytes))
+	respDumper := &streamDumper{Dumper: response.NewDumper(c.Response(), response.WithMaxBytes(maxBytes))}
 	c.SetResponse(respDumper)
 
 	return respDumper
 }
 
-// dumpReq processes the request for tracing, adding path parameters, headers, and body to the span.
-// It returns a response dumper if body dumping is enabled.
-func dumpReq(c *echo.Context, config OtelConfig, span oteltrace.Span, request *http.Request, skipReqBody, skipRespBody bool) *response.Dumper {
+// dumpReq processes the request for tracing, adding path parameters and headers to the span.
+// It returns a response dumper and the captured request body attribute if body dumping is
+// enabled; the body is attached later by dumpBodies once the outcome is known.
+func dumpReq(c *echo.Context, config OtelConfig, span oteltrace.Span, request *http.Request, skipReqBody, skipRespBody bool) (*streamDumper, capturedBody) {
 	// Add path parameters
 	addPathParameters(c, config, span)
 
+	// Add URL path & query
+	dumpURL(request, config, span)
+
 	// Dump request headers
 	if config.AreHeadersDump {
-		setAttr(span, config, dumpHeaders("http.request.headers", request.Header, config.HeaderSkipper)...)
+		setAttr(span, config, dumpHeaders(config.AttributeSchema.keys().requestHeaderPrefix, request.Header, config.HeaderAttributeNames, config)...)
 	}
 
 	// Dump request & response body
-	var respDumper *response.Dumper
+	var (
+		respDumper *streamDumper
+		reqBody    capturedBody
+	)
 
 	if config.IsBodyDump {
 		// Dump request body
-		dumpRequestBody(request, config, span, skipReqBody)
+		reqBody = dumpRequestBody(request, config, span, skipReqBody)
 
 		// Only install the response dumper if we plan to use it; otherwise the
 		// response is buffered for the full request lifetime for nothing.
 		if !skipRespBody {
-			respDumper = setupResponseDumper(c, config.MaxBodyDumpSize)
+			maxSize := config.MaxBodyDumpSize
+			if config.StreamingResponse && maxSize < 0 {
+				// Never buffer an unbounded stream.
+				maxSize = defaultMaxBodyDumpSize
+			}
+
+			respDumper = setupResponseDumper(c, maxSize)
 		}
 	}
 
-	return respDumper
+	return respDumper, reqBody
 }
 
-// setSpanStatus sets the span status based on the HTTP status code, attaching
-// a human-readable message for error statuses.
-func setSpanStatus(span oteltrace.Span, status int, err error) {
-	switch {
-	case status >= 400:
-		msg := http.StatusText(status)
-		if err != nil {
-			msg = err.Error()
-		}
-
-		span.SetStatus(codes.Error, msg)
-	case status >= 200:
-		span.SetStatus(codes.Ok, "")
+// setSpanStatus sets the span status chosen by the StatusClassifier for the
+// HTTP status code and handler error. The description goes through the
+// ValueScrubber. It returns the code it set.
+func setSpanStatus(c *echo.Context, config OtelConfig, span oteltrace.Span, status int, err error) codes.Code {
+	code, description := config.StatusClassifier(c, status, err)
+	if code != codes.Unset {
+		span.SetStatus(code, scrubString(config, attrStatusDescription, description))
 	}
+
+	return code
 }
 
 // dumpResponseHeaders dumps the response headers to the span.
 func dumpResponseHeaders(c *echo.Context, config OtelConfig, span oteltrace.Span) {
 	if config.AreHeadersDump {
-		setAttr(span, config, dumpHeaders("http.response.headers", c.Response().Header(), config.HeaderSkipper)...)
+		setAttr(span, config, dumpHeaders(config.AttributeSchema.keys().responseHeaderPrefix, c.Response().Header(), config.ResponseHeaderAttributeNames, config)...)
 	}
 }
 
-// dumpResponseBody dumps the response body to the span. Only called when a
-// response dumper was installed, which implies the body was not skipped.
-func dumpResponseBody(c *echo.Context, respDumper *response.Dumper, config OtelConfig, span oteltrace.Span) {
+// dumpResponseBody returns the response body as an attribute. Only called when
+// a response dumper was installed, which implies the body was not skipped.
+func dumpResponseBody(c *echo.Context, config OtelConfig, respDumper *streamDumper) attribute.KeyValue {
+	header := c.Response().Header()
+	ct := header.Get(echo.HeaderContentType)
+	buf := respDumper.Body()
+	truncated := respDumper.BytesWritten() > len(buf)
+
+	// Decompress the captured copy only
+	if enc := contentEncoding(header.Get(headerContentEncoding)); enc != "" {
+		decoded, decodedTruncated, ok := decodeBody(enc, buf, truncated, config.MaxBodyDumpSize)
+		if !ok {
+			return attribute.String(config.AttributeSchema.keys().responseBody, bodyNonText)
+		}
+
+		buf, truncated = decoded, decodedTruncated
+	}
+
+	if codec, ok := rpcCodecFor(ct); ok && config.IsRPC {
+		if body, ok := codec.bodyText(buf, truncated, config.BodyRedactor); ok {
+			return attribute.String(config.AttributeSchema.keys().responseBody, body)
+		}
+	}
+
 	respBody := bodyNonText
-	if isTextualContentType(c.Response().Header().Get(echo.HeaderContentType)) {
-		respBody = strings.ToValidUTF8(respDumper.GetResponse(), "")
-		if respDumper.BytesWritten() > len(respDumper.Body()) {
+	if isTextualContentType(ct) {
+		respBody = strings.ToValidUTF8(string(buf), "")
+		if truncated {
 			respBody += bodyTruncated
+		} else if config.BodyRedactor != nil {
+			respBody = config.BodyRedactor(ct, respBody)
 		}
 	}
 
-	setAttr(span, config, attribute.String(attrResponseBody, respBody))
+	return attribute.String(config.AttributeSchema.keys().responseBody, respBody)
 }
 
-// dumpResp processes the response for tracing, adding status, headers, and body to the span.
-func dumpResp(c *echo.Context, config OtelConfig, span oteltrace.Span, respDumper *response.Dumper, err error, skipRespBody bool) {
-	status := responseStatus(c, respDumper, err)
-
+// dumpResp processes the response for tracing, adding status, error type and headers to the span.
+// It returns the span status code.
+func dumpResp(c *echo.Context, config OtelConfig, span oteltrace.Span, status int, err error, errType string) codes.Code {
 	// Set span status based on HTTP status code
-	setSpanStatus(span, status, err)
+	code := setSpanStatus(c, config, span, status, err)
 
-	// Add status code attribute if available
+	// Add status code & error type attributes if available
+	attrs := make([]attribute.KeyValue, 0, 2)
 	if status > 0 {
-		setAttr(span, config, semconv.HTTPResponseStatusCode(status))
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(status))
 	}
 
+	if errType != "" {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errType))
+	}
+
+	setAttr(span, config, attrs...)
+
 	// Dump response headers
 	dumpResponseHeaders(c, config, span)
 
-	// Dump response body. When the response was skipped up-front we never
-	// installed a dumper, but still emit the marker attribute for parity with
-	// the request side.
-	if config.IsBodyDump {
-		switch {
-		case respDumper != nil:
-			dumpResponseBody(c, respDumper, config, span)
-		case skipRespBody:
-			setAttr(span, config, attribute.String(attrResponseBody, bodyExcluded))
+	return code
+}
+
+// shouldDumpBodies reports whether captured bodies are attached to the span.
+// With BodyDumpOnErrorOnly they are kept only for requests that ended with an
+// Error span status or took at least BodyDumpLatencyThreshold.
+func shouldDumpBodies(config OtelConfig, code codes.Code, elapsed time.Duration) bool {
+	if !config.BodyDumpOnErrorOnly || code == codes.Error {
+		return true
+	}
+
+	return config.BodyDumpLatencyThreshold > 0 && elapsed >= config.BodyDumpLatencyThreshold
+}
+
+// dumpBodies records the captured request body and the response body through
+// BodySink. When the response was skipped up-front we never installed a dumper,
+// but still emit the marker attribute for parity with the request side.
+func dumpBodies(c *echo.Context, config OtelConfig, span oteltrace.Span, reqBody capturedBody, respDumper *streamDumper, skipRespBody bool) {
+	keys := config.AttributeSchema.keys()
+
+	attrs := make([]attribute.KeyValue, 0, 4)
+	if reqBody.attr.Valid() {
+		attrs = append(attrs, sinkBody(c, config, span, reqBody, keys.requestBodyDumpSize, keys.requestBodyDumpSHA256)...)
+
+		if enc := contentEncoding(c.Request().Header.Get(headerContentEncoding)); enc != "" {
+			attrs = append(attrs, attribute.String(attrRequestBodyEncoding, enc))
+		}
+	}
+
+	switch {
+	case respDumper != nil:
+		respBody := capturedBody{attr: dumpResponseBody(c, config, respDumper), size: len(respDumper.Body())}
+		attrs = append(attrs, sinkBody(c, config, span, respBody, keys.responseBodyDumpSize, keys.responseBodyDumpSHA256)...)
+
+		if enc := contentEncoding(c.Response().Header().Get(headerContentEncoding)); enc != "" {
+			attrs = append(attrs, attribute.String(attrResponseBodyEncoding, enc))
 		}
+	case skipRespBody:
+		attrs = append(attrs, attribute.String(keys.responseBody, bodyExcluded))
 	}
+
+	setAttr(span, config, attrs...)
 }
 
 // createSpanName builds an OTel-conformant span name: "{METHOD} {route}".
@@ -327,7 +633,7 @@ func createSpanName(request *http.Request, path string) string {
 
 // createSpanOptions creates span options with common HTTP attributes using
 // current OpenTelemetry semantic conventions.
-func createSpanOptions(request *http.Request, realIP, requestID string) []oteltrace.SpanStartOption {
+func createSpanOptions(request *http.Request, realIP, requestID string, config OtelConfig) []oteltrace.SpanStartOption {
 	attrs := []attribute.KeyValue{
 		semconv.HTTPRequestMethodKey.String(request.Method),
 		semconv.URLScheme(request.URL.Scheme),
@@ -340,7 +646,8 @@ func createSpanOptions(request *http.Request, realIP, requestID string) []oteltr
 	}
 
 	if requestID != "" {
-		attrs = append(attrs, attribute.String("http.request.id", requestID))
+		fromResponse := request.Header.Get(echo.HeaderXRequestID) == ""
+		attrs = append(attrs, config.AttributeSchema.keys().requestID(requestID, fromResponse))
 	}
 
 	if name, version := splitProto(request.Proto); name != "" {
@@ -352,7 +659,7 @@ func createSpanOptions(request *http.Request, realIP, requestID string) []oteltr
 
 	return []oteltrace.SpanStartOption{
 		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
-		oteltrace.WithAttributes(attrs...),
+		oteltrace.WithAttributes(prepareAttrs(config, attrs...)...),
 	}
 }
 
@@ -386,10 +693,23 @@ func createSpan(c *echo.Context, config OtelConfig) (*http.Request, oteltrace.Sp
 	ctx := config.Propagator.Extract(savedCtx, propagation.HeaderCarrier(request.Header))
 
 	// Create span
-	opName := createSpanName(request, c.Path())
-	opts := createSpanOptions(request, realIP, requestID)
+	opName := config.SpanNameFormatter(c, routeTemplate(c))
+	opts := createSpanOptions(request, realIP, requestID, config)
+	if config.InboundContextSkipper(c) {
+		opts = append(opts, untrustedParentOptions(ctx)...)
+		ctx = baggage.ContextWithoutBaggage(ctx)
+	}
+
+	if links := extractLinks(c, config); len(links) > 0 {
+		opts = append(opts, oteltrace.WithLinks(links...))
+	}
+
 	ctx, span := tracer.Start(ctx, opName, opts...)
 
+	if config.IsBaggageDump {
+		setAttr(span, config, baggageAttrs(ctx, config)...)
+	}
+
 	// Return cleanup function: restore the original request/response so any
 	// outer middleware sees the values it handed us, then end the span.
 	return request, span, ctx, func() {
@@ -405,6 +725,14 @@ func setDefaultValues(config *OtelConfig) {
 		config.TracerProvider = otel.GetTracerProvider()
 	}
 
+	if config.MeterProvider == nil {
+		config.MeterProvider = otel.GetMeterProvider()
+	}
+
+	if config.LoggerProvider == nil {
+		config.LoggerProvider = logglobal.GetLoggerProvider()
+	}
+
 	if config.Propagator == nil {
 		config.Propagator = otel.GetTextMapPropagator()
 	}
@@ -417,24 +745,57 @@ func setDefaultValues(config *OtelConfig) {
 		config.BodySkipper = defaultBodySkipper
 	}
 
+	if config.InboundContextSkipper == nil {
+		config.InboundContextSkipper = middleware.DefaultSkipper
+	}
+
+	if config.TraceResponseSkipper == nil {
+		config.TraceResponseSkipper = middleware.DefaultSkipper
+	}
+
 	if config.HeaderSkipper == nil {
 		config.HeaderSkipper = defaultHeaderSkipper
 	}
 
+	if config.SpanNameFormatter == nil {
+		config.SpanNameFormatter = DefaultSpanNameFormatter
+	}
+
+	if config.StatusClassifier == nil {
+		config.StatusClassifier = LegacyStatusClassifier
+	}
+
+	if config.ErrorTypeClassifier == nil {
+		config.ErrorTypeClassifier = GoTypeErrorClassifier
+	}
+
+	if config.QueryParamSkipper == nil {
+		config.QueryParamSkipper = defaultQueryParamSkipper
+	}
+
+	if config.BaggageAttributePrefix == "" {
+		config.BaggageAttributePrefix = defaultBaggageAttributePrefix
+	}
+
+	config.HeaderAllowlist = canonicalHeaderList(config.HeaderAllowlist)
+	config.HeaderAttributeNames = canonicalHeaderMap(config.HeaderAttributeNames)
+	config.ResponseHeaderAttributeNames = canonicalHeaderMap(config.ResponseHeaderAttributeNames)
+
 	if config.MaxBodyDumpSize == 0 {
 		config.MaxBodyDumpSize = defaultMaxBodyDumpSize
 	}
 }
 
-func responseStatus(c *echo.Context, respDumper *response.Dumper, err error) int {
-	if respDumper != nil {
-		return respDumper.StatusCode()
+func responseStatus(c *echo.Context, respDumper *streamDumper, err error) int {
+	resp, unwrapErr := echo.UnwrapResponse(c.Response())
+	if unwrapErr != nil {
+		resp = nil
 	}
 
-	// On handler error the response has not yet been written (Echo's
-	// HTTPErrorHandler runs after this middleware returns), so prefer the
-	// error's HTTPStatusCoder. Generic errors map to 500.
-	if err != nil {
+	// On handler error the response has usually not been written yet (Echo's
+	// HTTPErrorHandler runs after this middleware returns, unless HandleError
+	// is set), so prefer the error's HTTPStatusCoder. Generic errors map to 500.
+	if err != nil && (resp == nil || !resp.Committed) {
 		var hsc echo.HTTPStatusCoder
 		if errors.As(err, &hsc) {
 			return hsc.StatusCode()
@@ -443,32 +804,52 @@ func responseStatus(c *echo.Context, respDumper *response.Dumper, err error) int
 		return http.StatusInternalServerError
 	}
 
-	resp, unwrapErr := echo.UnwrapResponse(c.Response())
-	if unwrapErr == nil && resp != nil {
+	if respDumper != nil {
+		return respDumper.StatusCode()
+	}
+
+	if resp != nil {
 		return resp.Status
 	}
 
 	return 0
 }
 
-func dumpHeaders(prefix string, h http.Header, skip HeaderSkipper) []attribute.KeyValue {
-	attrs := make([]attribute.KeyValue, 0, len(h))
+// dumpHeaders converts headers to span attributes. With a HeaderAllowlist only
+// the listed headers are kept; names overrides the generated attribute key for
+// individual headers.
+func dumpHeaders(prefix string, h http.Header, names map[string]attribute.Key, config OtelConfig) []attribute.KeyValue {
+	size := len(h)
+	if len(config.HeaderAllowlist) > 0 {
+		size = min(size, len(config.HeaderAllowlist))
+	}
+
+	attrs := make([]attribute.KeyValue, 0, size)
 
 	for k, v := range h {
-		if skip != nil && skip(k) {
-			attrs = append(attrs, formatKey(k, prefix).String("[redacted]"))
+		if len(config.HeaderAllowlist) > 0 && !slices.Contains(config.HeaderAllowlist, k) {
+			continue
+		}
+
+		key, ok := names[k]
+		if !ok {
+			key = config.AttributeSchema.keys().headerKey(k, prefix)
+		}
+
+		if config.HeaderSkipper != nil && config.HeaderSkipper(k) {
+			attrs = append(attrs, key.String(valueRedacted))
 			continue
 		}
 
-		attrs = append(attrs, formatKey(k, prefix).StringSlice(v))
+		attrs = append(attrs, key.StringSlice(v))
 	}
 
 	return attrs
 }
 
 // recordPanic attaches a recovered panic value to the span as an error event
-// and sets the span status to Error.
-func recordPanic(span oteltrace.Span, r any) {
+// and sets the span status to Error. It returns the panic value as an error.
+func recordPanic(span oteltrace.Span, config OtelConfig, r any) error {
 	var err error
 	if e, ok := r.(error); ok {
 		err = e
@@ -476,8 +857,10 @@ func recordPanic(span oteltrace.Span, r any) {
 		err = fmt.Errorf("panic: %v", r)
 	}
 
-	span.RecordError(err, oteltrace.WithStackTrace(true))
-	span.SetStatus(codes.Error, err.Error())
+	recordError(span, config, err, oteltrace.WithStackTrace(true))
+	span.SetStatus(codes.Error, scrubString(config, attrStatusDescription, err.Error()))
+
+	return err
 }
 
 // Middleware returns a OpenTelemetry middleware with default config
@@ -490,8 +873,14 @@ func MiddlewareWithConfig(config OtelConfig) echo.MiddlewareFunc {
 	// Ensure default values are set
 	setDefaultValues(&config)
 
+	metrics := newServerMetrics(config.MeterProvider)
+	overrides := compileRouteOverrides(config)
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c *echo.Context) error {
+			// Apply per-route overrides, if any
+			config := overrides.resolve(c, config)
+
 			// Skip middleware if necessary
 			if shouldSkipMiddleware(c, config) {
 				return next(c)
@@ -501,41 +890,162 @@ func MiddlewareWithConfig(config OtelConfig) echo.MiddlewareFunc {
 			request, span, ctx, endSpan := createSpan(c, config)
 			defer endSpan()
 
-			// Record panics on the span and re-panic so upstream recovery
-			// middleware (Echo's Recover, etc.) still works.
+			// Expose the trace context to the client before the response is flushed
+			writeTraceResponseHeaders(c, config, span.SpanContext())
+
+			start := time.Now()
+			endActive := metrics.startRequest(ctx, request)
+			defer endActive()
+
+			// End the HTTP span when the connection is upgraded (e.g. to a
+			// WebSocket) instead of keeping it open for the connection's life.
+			upgraded := func() bool { return false }
+
+			// Captured bodies, set once the request has been dumped
+			var (
+				respDumper   *streamDumper
+				reqBody      capturedBody
+				skipRespBody bool
+			)
+
+			// Record panics on the span and as a 500 in the metrics, then
+			// re-panic so upstream recovery middleware (Echo's Recover, etc.)
+			// still works.
 			defer func() {
 				if r := recover(); r != nil {
-					recordPanic(span, r)
+					err := recordPanic(span, config, r)
+					if !upgraded() {
+						// A panic always ends the span with Error, so the
+						// bodies are kept even with BodyDumpOnErrorOnly.
+						if config.IsBodyDump && span.IsRecording() {
+							dumpBodies(c, config, span, reqBody, respDumper, skipRespBody)
+						}
+
+						status := http.StatusInternalServerError
+						metrics.endRequest(ctx, c, request, start, status, config.ErrorTypeClassifier(c, status, err))
+					}
+
 					panic(r)
 				}
 			}()
 
+			// Remember the route seen before the handler; it may still be
+			// unresolved if the middleware runs before routing.
+			startRoute := routeTemplate(c)
+
+			// Requests offering an upgrade (including h2c, which net/http never
+			// performs) keep the normal dump path; only a 101 or a Hijack seen
+			// by the upgrade writer ends the HTTP span early.
+			if isUpgradeRequest(request) {
+				upgraded = traceUpgrade(c, config, span, func() {
+					updateRoute(c, config, span, startRoute)
+					dumpResp(c, config, span, http.StatusSwitchingProtocols, nil, "")
+					span.End()
+					metrics.endRequest(ctx, c, request, start, http.StatusSwitchingProtocols, "")
+					endActive()
+				})
+			}
+
 			// Skip attribute/body/header processing if span is not recording.
 			if !span.IsRecording() {
 				c.SetRequest(request.WithContext(ctx))
 
-				return next(c)
+				err := next(c)
+				if upgraded() {
+					return err
+				}
+
+				handleError(c, config, err)
+
+				status := responseStatus(c, nil, err)
+				metrics.endRequest(ctx, c, request, start, status, config.ErrorTypeClassifier(c, status, err))
+
+				return err
 			}
 
 			// Determine if request/response bodies should be skipped
 			skipReqBody := false
-			skipRespBody := false
 
 			if config.IsBodyDump {
 				skipReqBody, skipRespBody = config.BodySkipper(c)
 			}
 
+			// Count flushes of streamed responses; installed below the
+			// response dumper so its Flush reaches the stream writer.
+			var stream *streamWriter
+			if shouldWatchStream(config, skipRespBody) {
+				stream = watchStream(c, config, span)
+			}
+
 			// Process request for tracing
-			respDumper := dumpReq(c, config, span, request, skipReqBody, skipRespBody)
+			respDumper, reqBody = dumpReq(c, config, span, request, skipReqBody, skipRespBody)
+
+			// Never buffer an unbounded stream
+			boundStreamDump(c, config, stream, respDumper)
+
+			// Record the GraphQL operation from the buffered request body
+			if config.IsGraphQL {
+				extractGraphQL(c, config, span)
+			}
+
+			// Record the RPC call of matched gRPC-Web and Connect routes
+			isRPC := config.IsRPC && extractRPC(c, config, span)
 
 			// Setup request context with the span
 			c.SetRequest(request.WithContext(ctx))
 
+			// Add user-defined request attributes; copied because
+			// prepareAttrs rewrites in place and hooks may return a shared slice.
+			if config.RequestAttributes != nil {
+				setAttr(span, config, append([]attribute.KeyValue(nil), config.RequestAttributes(c)...)...)
+			}
+
+			// Record lifecycle events around the handler
+			watchResponseHeaders(c, config, span)
+			addEvent(span, config, eventHandlerStart)
+
 			// Call next middleware/controller and handle errors
 			err := processNextHandler(c, next, config, span)
+			if upgraded() {
+				return err
+			}
+
+			addEvent(span, config, eventHandlerEnd, attribute.Int64(eventAttrBytes, responseSize(c)))
+
+			// Rename the span if routing resolved during next()
+			updateRoute(c, config, span, startRoute)
+
+			if config.IsRPC && !isRPC {
+				isRPC = extractRPC(c, config, span)
+			}
 
 			// Process response for tracing
-			dumpResp(c, config, span, respDumper, err, skipRespBody)
+			status := responseStatus(c, respDumper, err)
+			errType := config.ErrorTypeClassifier(c, status, err)
+
+			if isRPC {
+				rpcStatus(c, config, span, status, respDumper)
+				resolveRPCMethod(c, config, span, status)
+			}
+
+			code := dumpResp(c, config, span, status, err, errType)
+
+			if stream != nil {
+				recordStream(c, config, span, stream)
+			}
+
+			// Add user-defined response attributes (copied, see above)
+			if config.ResponseAttributes != nil {
+				setAttr(span, config, append([]attribute.KeyValue(nil), config.ResponseAttributes(c, status, err)...)...)
+			}
+
+			// Attach bodies unless deferred capture decides they are not needed
+			if config.IsBodyDump && shouldDumpBodies(config, code, time.Since(start)) {
+				dumpBodies(c, config, span, reqBody, respDumper, skipRespBody)
+			}
+
+			// Record HTTP server metrics
+			metrics.endRequest(ctx, c, request, start, status, errType)
 
 			return err
 		}
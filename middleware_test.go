@@ -3,11 +3,14 @@ package echootelmiddleware
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
@@ -344,7 +347,7 @@ func TestSetSpanStatus(t *testing.T) {
 			tracer := provider.Tracer(tracerName)
 
 			_, span := tracer.Start(context.Background(), "test")
-			setSpanStatus(span, tc.statusCode, nil)
+			setSpanStatus(nil, OtelConfig{StatusClassifier: LegacyStatusClassifier}, span, tc.statusCode, nil)
 			span.End()
 
 			spans := sr.Ended()
@@ -683,6 +686,130 @@ func TestPathParameterAttribute(t *testing.T) {
 	assert.Contains(t, attrs, attribute.String("http.path.id", userID))
 }
 
+func TestURLPathAndQueryAttributes(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		IsURLPathDump:  true,
+		IsURLQueryDump: true,
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+
+	r := httptest.NewRequest("GET", userURL+"?active=true&api_key=s3cr3t", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("url.path", userURL))
+	assert.Contains(t, attrs, attribute.String("url.query", "active=true&api_key=[redacted]"))
+}
+
+func TestURLAttributesDisabledByDefault(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{TracerProvider: provider}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+
+	r := httptest.NewRequest("GET", userURL+"?active=true", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.False(t, hasAttrPrefix(attrs, "url.path"))
+	assert.False(t, hasAttrPrefix(attrs, "url.query"))
+}
+
+func TestCustomAttributeHooks(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	var gotStatus int
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		LimitValueSize: 4,
+		RequestAttributes: func(c *echo.Context) []attribute.KeyValue {
+			assert.True(t, trace.SpanFromContext(c.Request().Context()).SpanContext().IsValid())
+
+			return []attribute.KeyValue{attribute.String("tenant.id", c.Request().Header.Get("X-Tenant-ID"))}
+		},
+		ResponseAttributes: func(c *echo.Context, status int, err error) []attribute.KeyValue {
+			gotStatus = status
+
+			return []attribute.KeyValue{
+				attribute.String("user.id", c.Get("user").(string)),
+				attribute.Bool("failed", err != nil),
+			}
+		},
+	}))
+	router.GET("/me", func(c *echo.Context) error {
+		c.Set("user", "u-1")
+		return echo.NewHTTPError(http.StatusTeapot, "short and stout")
+	})
+
+	r := httptest.NewRequest("GET", "/me", http.NoBody)
+	r.Header.Set("X-Tenant-ID", "acme-corp")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, gotStatus)
+
+	attrs := sr.Ended()[0].Attributes()
+	// Custom attributes go through the same limits as built-in ones.
+	assert.Contains(t, attrs, attribute.String("tenant.id", "acme"))
+	assert.Contains(t, attrs, attribute.String("user.id", "u-1"))
+	assert.Contains(t, attrs, attribute.Bool("failed", true))
+}
+
+func TestCustomAttributeHooksSharedSlice(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	// A package-level style slice returned as-is by both hooks.
+	shared := []attribute.KeyValue{attribute.String("tenant.id", "acme-corporation-long")}
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:     provider,
+		LimitValueSize:     4,
+		RequestAttributes:  func(*echo.Context) []attribute.KeyValue { return shared },
+		ResponseAttributes: func(*echo.Context, int, error) []attribute.KeyValue { return shared },
+	}))
+	router.GET("/me", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	// Run under -race: concurrent requests must not write to the shared slice.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/me", http.NoBody))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, attribute.String("tenant.id", "acme-corporation-long"), shared[0])
+
+	for _, span := range sr.Ended() {
+		assert.Contains(t, span.Attributes(), attribute.String("tenant.id", "acme"))
+	}
+}
+
 // failingReader is an io.ReadCloser that always returns an error on Read.
 type failingReader struct{}
 
@@ -784,6 +911,93 @@ func TestCustomHeaderSkipper(t *testing.T) {
 	assert.Contains(t, attrs, attribute.StringSlice("http.request.headers.authorization", []string{"Bearer leak-me"}))
 }
 
+func TestHeaderAllowlist(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:  provider,
+		AreHeadersDump:  true,
+		HeaderAllowlist: []string{"content-type", "X-Tenant-ID", "Authorization"},
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, userID)
+	})
+
+	r := httptest.NewRequest("GET", userURL, http.NoBody)
+	r.Header.Set(echo.HeaderContentType, "text/plain")
+	r.Header.Set("X-Tenant-ID", "acme")
+	r.Header.Set("X-Trace-Flavor", "vanilla")
+	r.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.StringSlice("http.request.headers.content_type", []string{"text/plain"}))
+	assert.Contains(t, attrs, attribute.StringSlice("http.request.headers.x_tenant_id", []string{"acme"}))
+	// Allowlisted headers are still subject to HeaderSkipper.
+	assert.Contains(t, attrs, attribute.String("http.request.headers.authorization", "[redacted]"))
+	assert.False(t, hasAttrPrefix(attrs, "http.request.headers.x_trace_flavor"))
+	assert.Contains(t, attrs, attribute.StringSlice("http.response.headers.content_type", []string{echo.MIMETextPlainCharsetUTF8}))
+}
+
+func TestHeaderAttributeNames(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		AreHeadersDump: true,
+		HeaderAttributeNames: map[string]attribute.Key{
+			"x-tenant-id":   "tenant.id",
+			"Authorization": "auth",
+		},
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, userID)
+	})
+
+	r := httptest.NewRequest("GET", userURL, http.NoBody)
+	r.Header.Set("X-Tenant-ID", "acme")
+	r.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.StringSlice("tenant.id", []string{"acme"}))
+	assert.Contains(t, attrs, attribute.String("auth", "[redacted]"))
+	assert.False(t, hasAttrPrefix(attrs, "http.request.headers.x_tenant_id"))
+}
+
+func TestHeaderAttributeNamesRequestAndResponse(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:               provider,
+		AreHeadersDump:               true,
+		HeaderAttributeNames:         map[string]attribute.Key{"X-Tenant-Id": "tenant.id"},
+		ResponseHeaderAttributeNames: map[string]attribute.Key{"X-Tenant-Id": "tenant.response_id"},
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		c.Response().Header().Set("X-Tenant-Id", "resp")
+		return c.String(http.StatusOK, userID)
+	})
+
+	r := httptest.NewRequest("GET", userURL, http.NoBody)
+	r.Header.Set("X-Tenant-Id", "req")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	// The response value no longer replaces the request value.
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.StringSlice("tenant.id", []string{"req"}))
+	assert.Contains(t, attrs, attribute.StringSlice("tenant.response_id", []string{"resp"}))
+}
+
 func TestPanicIsRecordedAndPropagated(t *testing.T) {
 	sr := tracetest.NewSpanRecorder()
 	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
@@ -957,6 +1171,108 @@ func TestResponseBodyTruncated(t *testing.T) {
 	assert.Contains(t, attrs, attribute.String("http.response.body", "abcd[truncated]"))
 }
 
+func TestBodyDumpOnErrorOnly(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		status    int
+		threshold time.Duration
+		delay     time.Duration
+		wantBody  bool
+	}{
+		{
+			name:     "success drops bodies",
+			status:   http.StatusOK,
+			wantBody: false,
+		},
+		{
+			name:     "error keeps bodies",
+			status:   http.StatusInternalServerError,
+			wantBody: true,
+		},
+		{
+			name:      "slow success keeps bodies",
+			status:    http.StatusOK,
+			threshold: time.Millisecond,
+			delay:     5 * time.Millisecond,
+			wantBody:  true,
+		},
+		{
+			name:      "fast success under threshold drops bodies",
+			status:    http.StatusOK,
+			threshold: time.Hour,
+			wantBody:  false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			var seenByHandler string
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:           provider,
+				IsBodyDump:               true,
+				BodyDumpOnErrorOnly:      true,
+				BodyDumpLatencyThreshold: tc.threshold,
+			}))
+			router.POST("/x", func(c *echo.Context) error {
+				b, err := io.ReadAll(c.Request().Body)
+				require.NoError(t, err)
+				seenByHandler = string(b)
+				time.Sleep(tc.delay)
+
+				return c.String(tc.status, "resp")
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("req"))
+			r.Header.Set(echo.HeaderContentType, "text/plain")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, "req", seenByHandler)
+			assert.Equal(t, "resp", w.Body.String())
+
+			attrs := sr.Ended()[0].Attributes()
+			if tc.wantBody {
+				assert.Contains(t, attrs, attribute.String("http.request.body", "req"))
+				assert.Contains(t, attrs, attribute.String("http.response.body", "resp"))
+			} else {
+				assert.False(t, hasAttrPrefix(attrs, "http.request.body"))
+				assert.False(t, hasAttrPrefix(attrs, "http.response.body"))
+			}
+		})
+	}
+}
+
+func TestBodyDumpOnPanic(t *testing.T) {
+	for _, onErrorOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("BodyDumpOnErrorOnly=%v", onErrorOnly), func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(middleware.Recover())
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:      provider,
+				IsBodyDump:          true,
+				BodyDumpOnErrorOnly: onErrorOnly,
+			}))
+			router.POST("/boom", func(_ *echo.Context) error {
+				panic("kaboom")
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/boom", strings.NewReader("req"))
+			r.Header.Set(echo.HeaderContentType, "text/plain")
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			assert.Contains(t, spans[0].Attributes(), attribute.String("http.request.body", "req"))
+		})
+	}
+}
+
 func TestSplitProto(t *testing.T) {
 	t.Run("http/1.1", func(t *testing.T) {
 		name, version := splitProto("HTTP/1.1")
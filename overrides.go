@@ -0,0 +1,88 @@
+package echootelmiddleware
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v5"
+)
+
+// RouteOverride adjusts the middleware config for requests matching a route.
+type RouteOverride struct {
+	// Method matches the request method; "" matches any method.
+	Method string
+
+	// Path matches the route template as returned by c.Path(), e.g.
+	// "/health". A trailing "*" matches every route with that prefix, e.g.
+	// "/admin/*".
+	Path string
+
+	// Apply modifies a copy of the base config for matching requests.
+	Apply func(config *OtelConfig)
+}
+
+// routeConfig is a RouteOverride with its resolved config.
+type routeConfig struct {
+	method string
+	path   string
+	prefix bool
+	config OtelConfig
+}
+
+// routeConfigs holds the resolved RouteOverrides in declaration order.
+type routeConfigs []routeConfig
+
+// compileRouteOverrides applies every RouteOverride to a copy of the base
+// config once, so no work beyond matching is done per request.
+func compileRouteOverrides(base OtelConfig) routeConfigs {
+	if len(base.RouteOverrides) == 0 {
+		return nil
+	}
+
+	rules := make(routeConfigs, 0, len(base.RouteOverrides))
+
+	for _, o := range base.RouteOverrides {
+		cfg := base
+		cfg.RouteOverrides = nil
+
+		if o.Apply != nil {
+			o.Apply(&cfg)
+		}
+
+		setDefaultValues(&cfg)
+
+		path, prefix := strings.CutSuffix(o.Path, "*")
+		rules = append(rules, routeConfig{
+			method: o.Method,
+			path:   path,
+			prefix: prefix,
+			config: cfg,
+		})
+	}
+
+	return rules
+}
+
+// resolve returns the config of the first rule matching the request's method
+// and route, or base if none matches. Before routing (e.g. under e.Pre()) the
+// route is empty and base is always returned.
+func (r routeConfigs) resolve(c *echo.Context, base OtelConfig) OtelConfig {
+	if len(r) == 0 || c.Request() == nil {
+		return base
+	}
+
+	method := c.Request().Method
+	path := c.Path()
+
+	for i := range r {
+		rule := &r[i]
+		if rule.method != "" && rule.method != method {
+			continue
+		}
+
+		if rule.path == path || (rule.prefix && strings.HasPrefix(path, rule.path)) {
+			return rule.config
+		}
+	}
+
+	return base
+}
@@ -0,0 +1,109 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRouteOverrides(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		AreHeadersDump: true,
+		RouteOverrides: []RouteOverride{
+			{
+				Path: "/admin/*",
+				Apply: func(c *OtelConfig) {
+					c.IsBodyDump = true
+				},
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/upload",
+				Apply: func(c *OtelConfig) {
+					c.IsBodyDump = true
+					c.MaxBodyDumpSize = 3
+				},
+			},
+			{
+				Path: "/health",
+				Apply: func(c *OtelConfig) {
+					c.AreHeadersDump = false
+				},
+			},
+		},
+	}))
+
+	handler := func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}
+	router.POST("/admin/users/:id", handler)
+	router.POST("/upload", handler)
+	router.POST("/public", handler)
+	router.GET("/health", handler)
+
+	for _, req := range []struct{ method, target string }{
+		{http.MethodPost, "/admin/users/1"},
+		{http.MethodPost, "/upload"},
+		{http.MethodPost, "/public"},
+		{http.MethodGet, "/health"},
+	} {
+		r := httptest.NewRequest(req.method, req.target, strings.NewReader("hello"))
+		r.Header.Set(echo.HeaderContentType, "text/plain")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	spans := sr.Ended()
+	require.Len(t, spans, 4)
+
+	admin := spans[0].Attributes()
+	assert.Contains(t, admin, attribute.String("http.request.body", "hello"))
+	assert.True(t, hasAttrPrefix(admin, "http.request.headers."))
+
+	upload := spans[1].Attributes()
+	assert.Contains(t, upload, attribute.String("http.request.body", "hel[truncated]"))
+
+	public := spans[2].Attributes()
+	assert.False(t, hasAttrPrefix(public, "http.request.body"))
+	assert.True(t, hasAttrPrefix(public, "http.request.headers."))
+
+	health := spans[3].Attributes()
+	assert.False(t, hasAttrPrefix(health, "http.request.headers."))
+}
+
+func TestRouteOverridesFirstMatchWins(t *testing.T) {
+	e := echo.New()
+	base := OtelConfig{}
+	base.RouteOverrides = []RouteOverride{
+		{Path: "/a", Apply: func(c *OtelConfig) { c.LimitValueSize = 1 }},
+		{Path: "/*", Apply: func(c *OtelConfig) { c.LimitValueSize = 2 }},
+		{Method: http.MethodPut, Path: "/b", Apply: func(c *OtelConfig) { c.LimitValueSize = 3 }},
+	}
+	setDefaultValues(&base)
+	rules := compileRouteOverrides(base)
+
+	resolve := func(method, path string) int {
+		c := e.NewContext(httptest.NewRequest(method, "/", http.NoBody), httptest.NewRecorder())
+		c.SetPath(path)
+
+		return rules.resolve(c, base).LimitValueSize
+	}
+
+	assert.Equal(t, 1, resolve(http.MethodGet, "/a"))
+	assert.Equal(t, 2, resolve(http.MethodPut, "/b"))
+	assert.Equal(t, 0, resolve(http.MethodGet, "nomatch"))
+}
@@ -0,0 +1,190 @@
+package echootelmiddleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// BodyRedactor masks sensitive data in a captured body before it becomes the
+// http.request.body or http.response.body attribute. contentType is the raw
+// Content-Type header of the payload.
+type BodyRedactor func(contentType, body string) string
+
+// fieldPath is a parsed redaction path. Anchored paths ("$.a.b") match from
+// the document root; unanchored paths ("a.b") match at any depth.
+type fieldPath struct {
+	segments []string
+	anchored bool
+}
+
+// matches reports whether the path of keys leading to a field is selected by
+// p. A "*" segment matches any single key.
+func (p fieldPath) matches(keys []string) bool {
+	if len(keys) < len(p.segments) || (p.anchored && len(keys) != len(p.segments)) {
+		return false
+	}
+
+	keys = keys[len(keys)-len(p.segments):]
+	for i, seg := range p.segments {
+		if seg != "*" && seg != keys[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseFieldPath parses a redaction path, reporting false for empty paths.
+func parseFieldPath(path string) (fieldPath, bool) {
+	var p fieldPath
+
+	if rest, ok := strings.CutPrefix(path, "$."); ok {
+		p.anchored = true
+		path = rest
+	}
+
+	if path == "" {
+		return p, false
+	}
+
+	p.segments = strings.Split(path, ".")
+
+	// Unanchored paths match at any depth, so leading "*" segments may also
+	// match zero parents: "*.token" selects a top-level "token" too.
+	if !p.anchored {
+		for len(p.segments) > 1 && p.segments[0] == "*" {
+			p.segments = p.segments[1:]
+		}
+	}
+
+	return p, true
+}
+
+// fieldRedactor is the set of paths masked by FieldPathRedactor.
+type fieldRedactor []fieldPath
+
+// matches reports whether any configured path selects the given keys.
+func (r fieldRedactor) matches(keys []string) bool {
+	for _, p := range r {
+		if p.matches(keys) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FieldPathRedactor returns a BodyRedactor that replaces the values of the
+// given fields with "[redacted]" in JSON and application/x-www-form-urlencoded
+// bodies. Paths are dot-separated keys: "$.user.password" is anchored at the
+// document root, "*.token" matches a "token" field under any parent or at the
+// root, and "password" matches that field at any depth. Form keys are a single
+// segment, matched by unanchored paths and by "$.key". Arrays are traversed
+// transparently. Bodies that fail to parse, or have another content type, are
+// returned unchanged.
+func FieldPathRedactor(paths ...string) BodyRedactor {
+	r := make(fieldRedactor, 0, len(paths))
+
+	for _, path := range paths {
+		if p, ok := parseFieldPath(path); ok {
+			r = append(r, p)
+		}
+	}
+
+	return func(contentType, body string) string {
+		if len(r) == 0 {
+			return body
+		}
+
+		switch mt := mediaType(contentType); {
+		case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+			return r.redactJSON(body)
+		case mt == "application/x-www-form-urlencoded":
+			return r.redactForm(body)
+		}
+
+		return body
+	}
+}
+
+// redactJSON masks matching fields in a single JSON document. Object keys are
+// re-encoded in sorted order.
+func (r fieldRedactor) redactJSON(body string) string {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return body
+	}
+
+	doc = r.redactValue(doc, nil)
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(doc); err != nil {
+		return body
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// redactValue walks a decoded JSON value, replacing matching fields in place.
+func (r fieldRedactor) redactValue(v any, keys []string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			path := append(keys[:len(keys):len(keys)], k)
+			if r.matches(path) {
+				t[k] = valueRedacted
+				continue
+			}
+
+			t[k] = r.redactValue(child, path)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = r.redactValue(child, keys)
+		}
+	}
+
+	return v
+}
+
+// redactForm masks matching keys in a URL-encoded form body. Pairs are
+// rewritten in place, like redactQuery, so field order and encoding are kept
+// and the mask is not percent-encoded.
+func (r fieldRedactor) redactForm(body string) string {
+	var b strings.Builder
+
+	b.Grow(len(body))
+
+	for i, pair := range strings.Split(body, "&") {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+
+		key, _, hasValue := strings.Cut(pair, "=")
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		if !hasValue || !r.matches([]string{name}) {
+			b.WriteString(pair)
+			continue
+		}
+
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(valueRedacted)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFieldPathRedactor(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		paths       []string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "anchored path",
+			paths:       []string{"$.user.password"},
+			contentType: "application/json",
+			body:        `{"user":{"name":"bob","password":"hunter2"},"password":"keep"}`,
+			want:        `{"password":"keep","user":{"name":"bob","password":"[redacted]"}}`,
+		},
+		{
+			name:        "wildcard parent also matches the root",
+			paths:       []string{"*.token"},
+			contentType: "application/json; charset=utf-8",
+			body:        `{"token":"top","auth":{"token":"abc"}}`,
+			want:        `{"auth":{"token":"[redacted]"},"token":"[redacted]"}`,
+		},
+		{
+			name:        "anchored wildcard requires a parent",
+			paths:       []string{"$.*.token"},
+			contentType: "application/json",
+			body:        `{"token":"top","auth":{"token":"abc"}}`,
+			want:        `{"auth":{"token":"[redacted]"},"token":"top"}`,
+		},
+		{
+			name:        "unanchored matches at any depth and through arrays",
+			paths:       []string{"card"},
+			contentType: "application/vnd.api+json",
+			body:        `{"payments":[{"card":"4111","amount":1.50},{"card":{"n":"5500"}}]}`,
+			want:        `{"payments":[{"amount":1.50,"card":"[redacted]"},{"card":"[redacted]"}]}`,
+		},
+		{
+			name:        "invalid json returned unchanged",
+			paths:       []string{"password"},
+			contentType: "application/json",
+			body:        `{"password":"hunter2"`,
+			want:        `{"password":"hunter2"`,
+		},
+		{
+			name:        "form body",
+			paths:       []string{"$.password"},
+			contentType: "application/x-www-form-urlencoded",
+			body:        "user=bob&password=hunter2",
+			want:        "user=bob&password=[redacted]",
+		},
+		{
+			name:        "form body keeps order and encoding",
+			paths:       []string{"*.token"},
+			contentType: "application/x-www-form-urlencoded",
+			body:        "z=1&to%6Ben=abc&a=b+c&token=def",
+			want:        "z=1&to%6Ben=[redacted]&a=b+c&token=[redacted]",
+		},
+		{
+			name:        "other content type returned unchanged",
+			paths:       []string{"password"},
+			contentType: "text/plain",
+			body:        `{"password":"hunter2"}`,
+			want:        `{"password":"hunter2"}`,
+		},
+		{
+			name:        "no paths",
+			contentType: "application/json",
+			body:        `{"password":"hunter2"}`,
+			want:        `{"password":"hunter2"}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			redactor := FieldPathRedactor(tc.paths...)
+			assert.Equal(t, tc.want, redactor(tc.contentType, tc.body))
+		})
+	}
+}
+
+func TestBodyRedactorInMiddleware(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		IsBodyDump:     true,
+		BodyRedactor:   FieldPathRedactor("password", "$.token"),
+	}))
+	router.POST("/login", func(c *echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"token": "secret-token"})
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"login":"bob","password":"hunter2"}`))
+	r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	require.Contains(t, w.Body.String(), "secret-token")
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("http.request.body", `{"login":"bob","password":"[redacted]"}`))
+	assert.Contains(t, attrs, attribute.String("http.response.body", `{"token":"[redacted]"}`))
+}
+
+func TestBodyRedactorSkipsTruncatedBody(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	var called bool
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:  provider,
+		IsBodyDump:      true,
+		MaxBodyDumpSize: 4,
+		BodyRedactor: func(_, body string) string {
+			called = true
+			return body
+		},
+	}))
+	router.POST("/x", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader(`{"password":"hunter2"}`))
+	r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.False(t, called)
+	assert.Contains(t, sr.Ended()[0].Attributes(), attribute.String("http.request.body", `{"pa[truncated]`))
+}
@@ -0,0 +1,410 @@
+package echootelmiddleware
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	rpcKey = "echo-otel-middleware-rpc"
+
+	headerConnectProtocolVersion = "Connect-Protocol-Version"
+	headerGRPCStatus             = "Grpc-Status"
+
+	// envelopeHeaderSize is the size of the flags byte and big-endian length
+	// prefixing each gRPC-Web and Connect streaming message.
+	envelopeHeaderSize = 5
+	envelopeCompressed = 0x01
+	grpcWebTrailer     = 0x80
+	connectEndStream   = 0x02
+
+	grpcUnimplemented    = "12"
+	connectUnimplemented = "unimplemented"
+)
+
+// rpcCodec describes how an RPC protocol encodes messages.
+type rpcCodec struct {
+	system    attribute.KeyValue
+	enveloped bool // messages are length-prefixed envelopes
+	json      bool // messages use the JSON codec
+}
+
+// rpcCodecFor classifies an RPC Content-Type: application/grpc,
+// application/grpc-web and application/connect, with an optional
+// "+proto"/"+json" codec suffix.
+func rpcCodecFor(ct string) (rpcCodec, bool) {
+	mt := mediaType(ct)
+
+	switch {
+	case mt == "application/grpc-web-text" || strings.HasPrefix(mt, "application/grpc-web-text+"):
+		// Base64-encoded envelopes; dumped as binary.
+		return rpcCodec{system: semconv.RPCSystemGRPC}, true
+	case mt == "application/grpc" || mt == "application/grpc-web" ||
+		strings.HasPrefix(mt, "application/grpc+") || strings.HasPrefix(mt, "application/grpc-web+"):
+		return rpcCodec{system: semconv.RPCSystemGRPC, enveloped: true, json: strings.HasSuffix(mt, "+json")}, true
+	case strings.HasPrefix(mt, "application/connect+"):
+		return rpcCodec{system: semconv.RPCSystemConnectRPC, enveloped: true, json: strings.HasSuffix(mt, "+json")}, true
+	}
+
+	return rpcCodec{}, false
+}
+
+// requestRPCCodec classifies the request's RPC protocol. Unary Connect
+// requests use plain application/json or application/proto and are recognized
+// by their Connect-Protocol-Version header.
+func requestRPCCodec(r *http.Request) (rpcCodec, bool) {
+	ct := r.Header.Get(echo.HeaderContentType)
+	if codec, ok := rpcCodecFor(ct); ok {
+		return codec, true
+	}
+
+	if r.Header.Get(headerConnectProtocolVersion) == "" {
+		return rpcCodec{}, false
+	}
+
+	switch mt := mediaType(ct); mt {
+	case "application/json", "application/proto":
+		return rpcCodec{system: semconv.RPCSystemConnectRPC, json: mt == "application/json"}, true
+	}
+
+	return rpcCodec{}, false
+}
+
+// rpcCall identifies an RPC served over HTTP.
+type rpcCall struct {
+	codec    rpcCodec
+	service  string
+	method   string // "" until the method is known
+	wildcard bool   // the route ends in "/{service}/*"
+
+	status      string // gRPC status code or Connect error code, if any
+	serverError bool   // status is a server error
+}
+
+// detectRPC reports whether the request is a gRPC, gRPC-Web or Connect call to
+// a matched route. The service and method are the last two segments of the
+// route template, e.g. "/{package.Service}/{Method}", never of the raw path,
+// so they stay low-cardinality. A parameter or wildcard method segment leaves
+// the method unknown; for a service mounted at "/{package.Service}/*" it is
+// resolved by resolveRPCMethod once the handler answered.
+func detectRPC(c *echo.Context) (rpcCall, bool) {
+	codec, ok := requestRPCCodec(c.Request())
+	if !ok {
+		return rpcCall{}, false
+	}
+
+	route := routeTemplate(c)
+
+	i := strings.LastIndexByte(route, '/')
+	if i <= 0 {
+		return rpcCall{}, false
+	}
+
+	call := rpcCall{
+		codec:   codec,
+		service: route[strings.LastIndexByte(route[:i], '/')+1 : i],
+		method:  route[i+1:],
+	}
+
+	if !isStaticSegment(call.service) {
+		return rpcCall{}, false
+	}
+
+	if !isStaticSegment(call.method) {
+		call.wildcard = call.method == "*"
+		call.method = ""
+	}
+
+	return call, true
+}
+
+// isStaticSegment reports whether a route template segment is a literal, not
+// a parameter or wildcard.
+func isStaticSegment(segment string) bool {
+	return segment != "" && !strings.ContainsAny(segment, ":*")
+}
+
+// attrs returns the rpc.* attributes of the call.
+func (rc rpcCall) attrs() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		rc.codec.system,
+		semconv.RPCService(rc.service),
+	}
+
+	if rc.method != "" {
+		attrs = append(attrs, semconv.RPCMethod(rc.method))
+	}
+
+	return attrs
+}
+
+// extractRPC records the RPC call of the request and renames the span through
+// SpanNameFormatter. It reports whether the request is an RPC call.
+func extractRPC(c *echo.Context, config OtelConfig, span oteltrace.Span) bool {
+	call, ok := detectRPC(c)
+	if !ok {
+		return false
+	}
+
+	c.Set(rpcKey, call)
+	setAttr(span, config, call.attrs()...)
+	span.SetName(config.SpanNameFormatter(c, routeTemplate(c)))
+
+	return true
+}
+
+// resolveRPCMethod names the method of a call to a service mounted at
+// "/{package.Service}/*" after the last path segment, and renames the span.
+// It only does so once the handler answered something other than 404 or
+// Unimplemented, so unknown procedures cannot inflate the span names.
+func resolveRPCMethod(c *echo.Context, config OtelConfig, span oteltrace.Span, status int) {
+	call, ok := c.Get(rpcKey).(rpcCall)
+	if !ok || !call.wildcard || call.method != "" {
+		return
+	}
+
+	if status == http.StatusNotFound || call.status == grpcUnimplemented || call.status == connectUnimplemented {
+		return
+	}
+
+	method := c.Param("*")
+	if method == "" || strings.Contains(method, "/") {
+		return
+	}
+
+	call.method = method
+	c.Set(rpcKey, call)
+	setAttr(span, config, semconv.RPCMethod(method))
+	span.SetName(config.SpanNameFormatter(c, routeTemplate(c)))
+}
+
+// envelope is a length-prefixed gRPC-Web or Connect streaming message.
+type envelope struct {
+	flags byte
+	msg   []byte
+}
+
+// splitEnvelopes splits body into complete envelopes. rest holds the bytes of
+// an incomplete trailing envelope, e.g. when the body was truncated.
+func splitEnvelopes(body []byte) (envs []envelope, rest []byte) {
+	for len(body) >= envelopeHeaderSize {
+		size := binary.BigEndian.Uint32(body[1:envelopeHeaderSize])
+		if uint64(len(body)-envelopeHeaderSize) < uint64(size) {
+			break
+		}
+
+		end := envelopeHeaderSize + int(size)
+		envs = append(envs, envelope{flags: body[0], msg: body[envelopeHeaderSize:end]})
+		body = body[end:]
+	}
+
+	return envs, body
+}
+
+// bodyText returns the body to dump for the codec: binary codecs are reported
+// as non-text, and enveloped JSON messages are decoded one per line, each
+// passed through redact. ok is false for unary JSON, which is dumped as is.
+func (rc rpcCodec) bodyText(body []byte, truncated bool, redact BodyRedactor) (string, bool) {
+	if !rc.json {
+		return bodyNonText, true
+	}
+
+	if !rc.enveloped {
+		return "", false
+	}
+
+	envs, rest := splitEnvelopes(body)
+	msgs := make([]string, 0, len(envs))
+
+	for _, env := range envs {
+		msg := strings.ToValidUTF8(string(env.msg), "")
+
+		switch {
+		case env.flags&envelopeCompressed != 0:
+			msg = bodyNonText
+		case redact != nil:
+			msg = redact(echo.MIMEApplicationJSON, msg)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	text := strings.Join(msgs, "\n")
+	if truncated || len(rest) > 0 {
+		text += bodyTruncated
+	}
+
+	return text, true
+}
+
+// grpcWebStatus returns the grpc-status from the trailer envelope of a
+// gRPC-Web response body, if present.
+func grpcWebStatus(body []byte) (string, bool) {
+	envs, _ := splitEnvelopes(body)
+
+	for _, env := range envs {
+		if env.flags&grpcWebTrailer == 0 {
+			continue
+		}
+
+		for line := range strings.SplitSeq(string(env.msg), "\r\n") {
+			if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), headerGRPCStatus) {
+				return strings.TrimSpace(value), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// connectErrorCode returns the error code of a Connect error: the body of a
+// failed unary call, or the end-of-stream message of a streaming call.
+func connectErrorCode(status int, codec rpcCodec, body []byte) string {
+	var doc struct {
+		Code  string `json:"code"`
+		Error *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+
+	if !codec.enveloped {
+		if status == http.StatusOK || json.Unmarshal(body, &doc) != nil {
+			return ""
+		}
+
+		return doc.Code
+	}
+
+	envs, _ := splitEnvelopes(body)
+
+	for _, env := range envs {
+		if env.flags&connectEndStream != 0 && json.Unmarshal(env.msg, &doc) == nil && doc.Error != nil {
+			return doc.Error.Code
+		}
+	}
+
+	return ""
+}
+
+// grpcServerError reports whether a gRPC status code is a server error per the
+// semantic conventions: UNKNOWN, DEADLINE_EXCEEDED, UNIMPLEMENTED, INTERNAL,
+// UNAVAILABLE and DATA_LOSS.
+func grpcServerError(code int) bool {
+	switch code {
+	case 2, 4, 12, 13, 14, 15:
+		return true
+	}
+
+	return false
+}
+
+// connectServerError reports whether a Connect error code is a server error,
+// matching the gRPC codes of grpcServerError.
+func connectServerError(code string) bool {
+	switch code {
+	case "unknown", "deadline_exceeded", connectUnimplemented, "internal", "unavailable", "data_loss":
+		return true
+	}
+
+	return false
+}
+
+// rpcStatus records the gRPC status code or Connect error code of an RPC
+// response on the span and on the call stored in c. gRPC and Connect streaming
+// server errors are sent with HTTP 200, so the built-in StatusClassifiers check
+// the call to mark them as Error. gRPC-Web trailers and Connect end-of-stream
+// messages sent in the body are only seen when the response body is dumped.
+func rpcStatus(c *echo.Context, config OtelConfig, span oteltrace.Span, status int, respDumper *streamDumper) {
+	call, ok := c.Get(rpcKey).(rpcCall)
+	if !ok {
+		return
+	}
+
+	var body []byte
+	if respDumper != nil && respDumper.BytesWritten() == len(respDumper.Body()) {
+		body = respDumper.Body()
+	}
+
+	if call.codec.system == semconv.RPCSystemConnectRPC {
+		if code := connectErrorCode(status, call.codec, body); code != "" {
+			call.status, call.serverError = code, connectServerError(code)
+			setAttr(span, config, semconv.RPCConnectRPCErrorCodeKey.String(code))
+			c.Set(rpcKey, call)
+		}
+
+		return
+	}
+
+	header := c.Response().Header()
+
+	value := header.Get(headerGRPCStatus)
+	if value == "" {
+		value = header.Get(http.TrailerPrefix + headerGRPCStatus)
+	}
+
+	if value == "" {
+		value, _ = grpcWebStatus(body)
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+
+	call.status, call.serverError = value, grpcServerError(code)
+	setAttr(span, config, semconv.RPCGRPCStatusCodeKey.Int(code))
+	c.Set(rpcKey, call)
+}
+
+// RPCCallFromContext returns the service and method of an RPC call detected
+// with IsRPC, e.g. for a custom SpanNameFormatter. method is "" when the route
+// does not name it and, for a service mounted at "/{package.Service}/*", until
+// the handler answered.
+func RPCCallFromContext(c *echo.Context) (service, method string, ok bool) {
+	call, ok := c.Get(rpcKey).(rpcCall)
+	if !ok {
+		return "", "", false
+	}
+
+	return call.service, call.method, true
+}
+
+// RPCStatusFromContext returns the gRPC status code (e.g. "14") or Connect
+// error code (e.g. "not_found") of an RPC call detected with IsRPC. It is set
+// before the StatusClassifier runs, so custom classifiers can use it; ok is
+// false for other requests and for calls without a status.
+func RPCStatusFromContext(c *echo.Context) (status string, ok bool) {
+	call, ok := c.Get(rpcKey).(rpcCall)
+	if !ok || call.status == "" {
+		return "", false
+	}
+
+	return call.status, true
+}
+
+// rpcServerError reports whether the request is an RPC call that failed with a
+// server error, and returns the status description.
+func rpcServerError(c *echo.Context) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	call, ok := c.Get(rpcKey).(rpcCall)
+	if !ok || !call.serverError {
+		return "", false
+	}
+
+	if call.codec.system == semconv.RPCSystemConnectRPC {
+		return "connect error " + call.status, true
+	}
+
+	return "grpc-status " + call.status, true
+}
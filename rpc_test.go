@@ -0,0 +1,363 @@
+package echootelmiddleware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const rpcPath = "/acme.greet.v1.GreetService/Greet"
+
+// envelopes encodes messages as length-prefixed envelopes with the given flags.
+func envelopes(frames ...envelope) []byte {
+	var out []byte
+
+	for _, f := range frames {
+		out = append(out, f.flags)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(f.msg)))
+		out = append(out, f.msg...)
+	}
+
+	return out
+}
+
+func serveRPC(t *testing.T, config OtelConfig, header http.Header, body []byte, handler echo.HandlerFunc) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	config.IsRPC = true
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(config))
+	router.POST(rpcPath, handler)
+
+	r := httptest.NewRequest(http.MethodPost, rpcPath, bytes.NewReader(body))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+
+	return spans[0]
+}
+
+func TestGRPCWebJSON(t *testing.T) {
+	reqBody := envelopes(envelope{msg: []byte(`{"name":"bob","token":"s3cr3t"}`)})
+	respBody := envelopes(
+		envelope{msg: []byte(`{"greeting":"hi bob"}`)},
+		envelope{flags: grpcWebTrailer, msg: []byte("grpc-status: 14\r\ngrpc-message: unavailable\r\n")},
+	)
+
+	span := serveRPC(t, OtelConfig{
+		IsBodyDump:   true,
+		BodyRedactor: FieldPathRedactor("token"),
+	}, http.Header{echo.HeaderContentType: {"application/grpc-web+json"}}, reqBody, func(c *echo.Context) error {
+		return c.Blob(http.StatusOK, "application/grpc-web+json", respBody)
+	})
+
+	assert.Equal(t, "acme.greet.v1.GreetService/Greet", span.Name())
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, attribute.String("rpc.system", "grpc"))
+	assert.Contains(t, attrs, attribute.String("rpc.service", "acme.greet.v1.GreetService"))
+	assert.Contains(t, attrs, attribute.String("rpc.method", "Greet"))
+	assert.Contains(t, attrs, attribute.Int("rpc.grpc.status_code", 14))
+	assert.Contains(t, attrs, attribute.String("http.request.body", `{"name":"bob","token":"[redacted]"}`))
+	assert.Contains(t, attrs, attribute.String("http.response.body", "{\"greeting\":\"hi bob\"}\ngrpc-status: 14\r\ngrpc-message: unavailable\r\n"))
+}
+
+func TestGRPCWebBinary(t *testing.T) {
+	span := serveRPC(t, OtelConfig{
+		IsBodyDump:  true,
+		BodySkipper: func(*echo.Context) (bool, bool) { return false, false },
+	}, http.Header{echo.HeaderContentType: {"application/grpc-web+proto"}},
+		envelopes(envelope{msg: []byte{0x0a, 0x03, 'b', 'o', 'b'}}),
+		func(c *echo.Context) error {
+			// Trailers-only response.
+			c.Response().Header().Set("Grpc-Status", "5")
+			return c.NoContent(http.StatusOK)
+		})
+
+	// NOT_FOUND is not a server error; the HTTP classification applies.
+	assert.Equal(t, codes.Ok, span.Status().Code)
+
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, attribute.Int("rpc.grpc.status_code", 5))
+	assert.Contains(t, attrs, attribute.String("http.request.body", "[non-text content]"))
+	assert.Contains(t, attrs, attribute.String("http.response.body", "[non-text content]"))
+}
+
+func TestConnectUnaryError(t *testing.T) {
+	span := serveRPC(t, OtelConfig{IsBodyDump: true}, http.Header{
+		echo.HeaderContentType:     {echo.MIMEApplicationJSON},
+		"Connect-Protocol-Version": {"1"},
+	}, []byte(`{"name":"bob"}`), func(c *echo.Context) error {
+		return c.JSONBlob(http.StatusNotFound, []byte(`{"code":"not_found","message":"no such greeter"}`))
+	})
+
+	assert.Equal(t, "acme.greet.v1.GreetService/Greet", span.Name())
+
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, attribute.String("rpc.system", "connect_rpc"))
+	assert.Contains(t, attrs, attribute.String("rpc.connect_rpc.error_code", "not_found"))
+	assert.Contains(t, attrs, attribute.String("http.request.body", `{"name":"bob"}`))
+}
+
+func TestConnectStreamingJSON(t *testing.T) {
+	respBody := envelopes(
+		envelope{msg: []byte(`{"greeting":"hi"}`)},
+		envelope{flags: connectEndStream, msg: []byte(`{"error":{"code":"internal"}}`)},
+	)
+
+	span := serveRPC(t, OtelConfig{IsBodyDump: true, MaxBodyDumpSize: 30},
+		http.Header{echo.HeaderContentType: {"application/connect+json"}},
+		envelopes(envelope{msg: []byte(`{"name":"a"}`)}, envelope{msg: []byte(`{"name":"b"}`)}),
+		func(c *echo.Context) error {
+			return c.Blob(http.StatusOK, "application/connect+json", respBody)
+		})
+
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, attribute.String("rpc.system", "connect_rpc"))
+	// Truncated bodies keep only complete messages.
+	assert.Contains(t, attrs, attribute.String("http.request.body", `{"name":"a"}[truncated]`))
+	assert.Contains(t, attrs, attribute.String("http.response.body", `{"greeting":"hi"}[truncated]`))
+	// The end-of-stream message was not captured.
+	assert.False(t, hasAttrPrefix(attrs, "rpc.connect_rpc.error_code"))
+}
+
+func TestConnectStreamingError(t *testing.T) {
+	respBody := envelopes(
+		envelope{msg: []byte(`{"greeting":"hi"}`)},
+		envelope{flags: connectEndStream, msg: []byte(`{"error":{"code":"internal","message":"boom"}}`)},
+	)
+
+	for _, tc := range []struct {
+		name       string
+		classifier StatusClassifier
+	}{
+		{name: "legacy", classifier: LegacyStatusClassifier},
+		{name: "semconv", classifier: SemconvServerStatusClassifier},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			span := serveRPC(t, OtelConfig{IsBodyDump: true, StatusClassifier: tc.classifier},
+				http.Header{echo.HeaderContentType: {"application/connect+json"}}, nil,
+				func(c *echo.Context) error {
+					return c.Blob(http.StatusOK, "application/connect+json", respBody)
+				})
+
+			// The error is sent with HTTP 200 in the end-of-stream message.
+			assert.Equal(t, codes.Error, span.Status().Code)
+			assert.Equal(t, "connect error internal", span.Status().Description)
+			assert.Contains(t, span.Attributes(), attribute.String("rpc.connect_rpc.error_code", "internal"))
+		})
+	}
+}
+
+func TestDetectRPC(t *testing.T) {
+	grpcWeb := http.Header{echo.HeaderContentType: {"application/grpc-web"}}
+
+	for _, tc := range []struct {
+		name        string
+		route       string
+		header      http.Header
+		want        bool
+		wantService string
+		wantMethod  string
+	}{
+		{name: "grpc-web", route: rpcPath, header: grpcWeb, want: true, wantService: "acme.greet.v1.GreetService", wantMethod: "Greet"},
+		{name: "grpc", route: rpcPath, header: http.Header{echo.HeaderContentType: {"application/grpc+proto"}}, want: true, wantService: "acme.greet.v1.GreetService", wantMethod: "Greet"},
+		{name: "connect streaming", route: rpcPath, header: http.Header{echo.HeaderContentType: {"application/connect+proto"}}, want: true, wantService: "acme.greet.v1.GreetService", wantMethod: "Greet"},
+		{name: "plain json", route: rpcPath, header: http.Header{echo.HeaderContentType: {echo.MIMEApplicationJSON}}},
+		{name: "wildcard method", route: "/acme.greet.v1.GreetService/*", header: grpcWeb, want: true, wantService: "acme.greet.v1.GreetService"},
+		{name: "parameter service", route: "/:service/:method", header: grpcWeb},
+		{name: "missing service", route: "/Greet", header: grpcWeb},
+		{name: "unmatched route", header: grpcWeb},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, rpcPath, http.NoBody)
+			r.Header = tc.header
+
+			c := echo.New().NewContext(r, httptest.NewRecorder())
+			c.SetPath(tc.route)
+
+			call, ok := detectRPC(c)
+			assert.Equal(t, tc.want, ok)
+			assert.Equal(t, tc.wantService, call.service)
+			assert.Equal(t, tc.wantMethod, call.method)
+		})
+	}
+}
+
+func TestRPCWildcardService(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsRPC:          true,
+	}))
+	router.Any("/acme.greet.v1.GreetService/*", func(c *echo.Context) error {
+		switch c.Param("*") {
+		case "Greet":
+			return c.NoContent(http.StatusOK)
+		case "Legacy":
+			c.Response().Header().Set("Grpc-Status", "12")
+			return c.NoContent(http.StatusOK)
+		}
+
+		return c.NoContent(http.StatusNotFound)
+	})
+
+	for _, method := range []string{"Greet", "Legacy", "random-123", "a/b"} {
+		r := httptest.NewRequest(http.MethodPost, "/acme.greet.v1.GreetService/"+method, http.NoBody)
+		r.Header.Set(echo.HeaderContentType, "application/grpc-web")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	spans := sr.Ended()
+	require.Len(t, spans, 4)
+
+	assert.Equal(t, "acme.greet.v1.GreetService/Greet", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("rpc.method", "Greet"))
+
+	// Unimplemented and unknown procedures keep the route template.
+	for _, span := range spans[1:] {
+		assert.Equal(t, "POST /acme.greet.v1.GreetService/*", span.Name())
+		assert.Contains(t, span.Attributes(), attribute.String("rpc.service", "acme.greet.v1.GreetService"))
+		assert.False(t, hasAttrPrefix(span.Attributes(), "rpc.method"))
+	}
+}
+
+func TestRPCRequiresMatchedRoute(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsRPC:          true,
+	}))
+	router.POST("/users/:id", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/123", "/nope/abc-999", "/x/y/z/random-123"} {
+		r := httptest.NewRequest(http.MethodPost, path, http.NoBody)
+		r.Header.Set(echo.HeaderContentType, "application/grpc-web")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	spans := sr.Ended()
+	require.Len(t, spans, 3)
+
+	// The raw path never names the span or the rpc.* attributes.
+	assert.Equal(t, "POST /users/:id", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("rpc.service", "users"))
+	assert.False(t, hasAttrPrefix(spans[0].Attributes(), "rpc.method"))
+
+	for _, span := range spans[1:] {
+		assert.Equal(t, "HTTP POST", span.Name())
+		assert.False(t, hasAttrPrefix(span.Attributes(), "rpc."))
+	}
+}
+
+func TestRPCDisabled(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+	}))
+	router.POST(rpcPath, func(c *echo.Context) error {
+		c.Response().Header().Set("Grpc-Status", "14")
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, rpcPath, http.NoBody)
+	r.Header.Set(echo.HeaderContentType, "application/grpc-web")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "POST "+rpcPath, spans[0].Name())
+	assert.Equal(t, codes.Ok, spans[0].Status().Code)
+	assert.False(t, hasAttrPrefix(spans[0].Attributes(), "rpc."))
+}
+
+func TestRPCStatusCustomClassifier(t *testing.T) {
+	var seen string
+
+	span := serveRPC(t, OtelConfig{
+		StatusClassifier: func(c *echo.Context, status int, err error) (codes.Code, string) {
+			seen, _ = RPCStatusFromContext(c)
+
+			return codes.Unset, ""
+		},
+	}, http.Header{echo.HeaderContentType: {"application/grpc-web+proto"}}, nil, func(c *echo.Context) error {
+		c.Response().Header().Set("Grpc-Status", "14")
+		return c.NoContent(http.StatusOK)
+	})
+
+	// The user's classifier decides, and sees the gRPC status.
+	assert.Equal(t, "14", seen)
+	assert.Equal(t, codes.Unset, span.Status().Code)
+	assert.Contains(t, span.Attributes(), attribute.Int("rpc.grpc.status_code", 14))
+}
+
+func TestRPCWithPre(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Pre(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsRPC:          true,
+	}))
+	router.POST(rpcPath, func(c *echo.Context) error {
+		c.Response().Header().Set("Grpc-Status", "13")
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, rpcPath, http.NoBody)
+	r.Header.Set(echo.HeaderContentType, "application/grpc-web")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "acme.greet.v1.GreetService/Greet", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("rpc.method", "Greet"))
+}
+
+func TestRPCCallFromContext(t *testing.T) {
+	span := serveRPC(t, OtelConfig{
+		SpanNameFormatter: func(c *echo.Context, route string) string {
+			if service, method, ok := RPCCallFromContext(c); ok {
+				return "rpc " + service + "." + method
+			}
+
+			return DefaultSpanNameFormatter(c, route)
+		},
+	}, http.Header{echo.HeaderContentType: {"application/grpc-web+proto"}}, nil, func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	assert.Equal(t, "rpc acme.greet.v1.GreetService.Greet", span.Name())
+
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, rpcPath, http.NoBody), httptest.NewRecorder())
+	_, _, ok := RPCCallFromContext(c)
+	assert.False(t, ok)
+}
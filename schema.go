@@ -0,0 +1,107 @@
+package echootelmiddleware
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AttributeSchema selects the attribute keys used for headers, path
+// parameters, the request ID and bodies.
+type AttributeSchema uint8
+
+const (
+	// LegacySchema keeps the historical keys: http.request.headers.<name>
+	// (lowercase, '-' replaced by '_'), http.path.<param>, http.request.id
+	// and http.request.body / http.response.body.
+	LegacySchema AttributeSchema = iota
+
+	// SemconvSchema follows the OpenTelemetry semantic conventions:
+	// http.request.header.<name> (lowercase, '-' kept) and the request ID as
+	// http.request.header.x-request-id, or http.response.header.x-request-id
+	// when it was only set on the response. Path parameters and bodies, which
+	// have no standard key, are recorded as http.route.parameter.<param> and
+	// http.request.body.content / http.response.body.content.
+	SemconvSchema
+)
+
+// schemaKeys holds the attribute keys for one AttributeSchema.
+type schemaKeys struct {
+	requestHeaderPrefix  string
+	responseHeaderPrefix string
+	keepHeaderDashes     bool
+	pathParamPrefix      string
+	requestBody          string
+	responseBody         string
+
+	// Keys standing in for bodies sent to an event or log BodySink
+	requestBodyDumpSize    string
+	requestBodyDumpSHA256  string
+	responseBodyDumpSize   string
+	responseBodyDumpSHA256 string
+}
+
+var (
+	legacyKeys = schemaKeys{
+		requestHeaderPrefix:  "http.request.headers",
+		responseHeaderPrefix: "http.response.headers",
+		pathParamPrefix:      "http.path.",
+		requestBody:          "http.request.body",
+		responseBody:         "http.response.body",
+
+		requestBodyDumpSize:    "http.request.body.dump.size",
+		requestBodyDumpSHA256:  "http.request.body.dump.sha256",
+		responseBodyDumpSize:   "http.response.body.dump.size",
+		responseBodyDumpSHA256: "http.response.body.dump.sha256",
+	}
+
+	semconvKeys = schemaKeys{
+		requestHeaderPrefix:  "http.request.header",
+		responseHeaderPrefix: "http.response.header",
+		keepHeaderDashes:     true,
+		pathParamPrefix:      "http.route.parameter.",
+		requestBody:          "http.request.body.content",
+		responseBody:         "http.response.body.content",
+
+		requestBodyDumpSize:    "http.request.body.content.size",
+		requestBodyDumpSHA256:  "http.request.body.content.sha256",
+		responseBodyDumpSize:   "http.response.body.content.size",
+		responseBodyDumpSHA256: "http.response.body.content.sha256",
+	}
+)
+
+// keys returns the attribute keys for the schema. Unknown values fall back to
+// LegacySchema.
+func (s AttributeSchema) keys() *schemaKeys {
+	if s == SemconvSchema {
+		return &semconvKeys
+	}
+
+	return &legacyKeys
+}
+
+// headerKey returns the attribute key for a header under the given prefix.
+func (k *schemaKeys) headerKey(name, prefix string) attribute.Key {
+	if k.keepHeaderDashes {
+		return attribute.Key(prefix + "." + strings.ToLower(name))
+	}
+
+	return formatKey(name, prefix)
+}
+
+// requestID returns the attribute recording the request ID. Under
+// SemconvSchema an ID generated for the response (e.g. by
+// middleware.RequestID()) is recorded as a response header, since the client
+// never sent it.
+func (k *schemaKeys) requestID(id string, fromResponse bool) attribute.KeyValue {
+	if k.keepHeaderDashes {
+		prefix := k.requestHeaderPrefix
+		if fromResponse {
+			prefix = k.responseHeaderPrefix
+		}
+
+		return k.headerKey("X-Request-Id", prefix).StringSlice([]string{id})
+	}
+
+	return attribute.String("http.request.id", id)
+}
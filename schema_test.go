@@ -0,0 +1,91 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSemconvSchema(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:  provider,
+		AreHeadersDump:  true,
+		IsBodyDump:      true,
+		AttributeSchema: SemconvSchema,
+	}))
+	router.POST(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+
+	r := httptest.NewRequest(http.MethodPost, userURL, strings.NewReader("test"))
+	r.Header.Set(echo.HeaderContentType, "text/plain")
+	r.Header.Set(echo.HeaderXRequestID, "req-abc-123")
+	r.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	attrs := spans[0].Attributes()
+
+	assert.Contains(t, attrs, attribute.StringSlice("http.request.header.content-type", []string{"text/plain"}))
+	assert.Contains(t, attrs, attribute.String("http.request.header.authorization", "[redacted]"))
+	assert.Contains(t, attrs, attribute.StringSlice("http.response.header.content-type", []string{echo.MIMETextPlainCharsetUTF8}))
+	assert.Contains(t, attrs, attribute.StringSlice("http.request.header.x-request-id", []string{"req-abc-123"}))
+	assert.Contains(t, attrs, attribute.String("http.route.parameter.id", userID))
+	assert.Contains(t, attrs, attribute.String("http.request.body.content", "test"))
+	assert.Contains(t, attrs, attribute.String("http.response.body.content", userID))
+
+	assert.False(t, hasAttrPrefix(attrs, "http.request.headers."))
+	assert.False(t, hasAttrPrefix(attrs, "http.path."))
+	assert.False(t, hasAttrPrefix(attrs, "http.request.id"))
+}
+
+func TestSemconvSchemaResponseRequestID(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			// Generated before the span starts, like middleware.RequestID().
+			c.Response().Header().Set(echo.HeaderXRequestID, "gen-456")
+			return next(c)
+		}
+	})
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:  sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		AttributeSchema: SemconvSchema,
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, userURL, http.NoBody))
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	attrs := spans[0].Attributes()
+
+	// The client never sent the header.
+	assert.Contains(t, attrs, attribute.StringSlice("http.response.header.x-request-id", []string{"gen-456"}))
+	assert.False(t, hasAttrPrefix(attrs, "http.request.header.x-request-id"))
+}
+
+func TestSchemaHeaderKey(t *testing.T) {
+	assert.Equal(t, attribute.Key("http.request.headers.x_tenant_id"), LegacySchema.keys().headerKey("X-Tenant-Id", "http.request.headers"))
+	assert.Equal(t, attribute.Key("http.request.header.x-tenant-id"), SemconvSchema.keys().headerKey("X-Tenant-Id", "http.request.header"))
+	// Unknown schemas fall back to legacy keys.
+	assert.Equal(t, LegacySchema.keys(), AttributeSchema(42).keys())
+}
@@ -0,0 +1,200 @@
+package echootelmiddleware
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ValueScrubber masks sensitive substrings in a string attribute value. key is
+// the full attribute key, before any LimitNameSize truncation.
+type ValueScrubber func(key, value string) string
+
+// Detector finds sensitive substrings in attribute values. Every match of
+// Pattern for which Validate (if set) returns true is replaced with
+// "[redacted]".
+type Detector struct {
+	// Name identifies the detector, e.g. "email".
+	Name string
+
+	// Pattern matches candidate substrings.
+	Pattern *regexp.Regexp
+
+	// Validate optionally filters candidates, e.g. with a checksum, to cut
+	// down false positives.
+	Validate func(match string) bool
+}
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	creditCardPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	jwtPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	bearerPattern     = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	ibanPattern       = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`)
+)
+
+// EmailDetector detects e-mail addresses.
+func EmailDetector() Detector {
+	return Detector{Name: "email", Pattern: emailPattern}
+}
+
+// CreditCardDetector detects 13-19 digit card numbers (optionally separated by
+// spaces or dashes) that pass the Luhn check.
+func CreditCardDetector() Detector {
+	return Detector{Name: "credit_card", Pattern: creditCardPattern, Validate: luhnValid}
+}
+
+// JWTDetector detects JSON Web Tokens.
+func JWTDetector() Detector {
+	return Detector{Name: "jwt", Pattern: jwtPattern}
+}
+
+// BearerTokenDetector detects "Bearer <token>" credentials.
+func BearerTokenDetector() Detector {
+	return Detector{Name: "bearer_token", Pattern: bearerPattern}
+}
+
+// IBANDetector detects IBANs that pass the ISO 13616 mod-97 check.
+func IBANDetector() Detector {
+	return Detector{Name: "iban", Pattern: ibanPattern, Validate: ibanValid}
+}
+
+// RegexDetector returns a Detector for a custom regular expression. It panics
+// if expr does not compile, like regexp.MustCompile.
+func RegexDetector(name, expr string) Detector {
+	return Detector{Name: name, Pattern: regexp.MustCompile(expr)}
+}
+
+// PIIDetectors is the preset for personal data: e-mail addresses, credit card
+// numbers and IBANs.
+func PIIDetectors() []Detector {
+	return []Detector{EmailDetector(), CreditCardDetector(), IBANDetector()}
+}
+
+// CredentialDetectors is the preset for credentials: JWTs and bearer tokens.
+func CredentialDetectors() []Detector {
+	return []Detector{JWTDetector(), BearerTokenDetector()}
+}
+
+// AllDetectors combines PIIDetectors and CredentialDetectors.
+func AllDetectors() []Detector {
+	return append(CredentialDetectors(), PIIDetectors()...)
+}
+
+// NewValueScrubber returns a ValueScrubber that runs the given detectors over
+// every string attribute value. Attributes whose key is listed in skipKeys are
+// left untouched; a key ending in "*" skips every key with that prefix (e.g.
+// "http.request.headers.*").
+func NewValueScrubber(detectors []Detector, skipKeys ...string) ValueScrubber {
+	skipExact := make(map[string]struct{}, len(skipKeys))
+
+	var skipPrefixes []string
+
+	for _, k := range skipKeys {
+		if prefix, ok := strings.CutSuffix(k, "*"); ok {
+			skipPrefixes = append(skipPrefixes, prefix)
+			continue
+		}
+
+		skipExact[k] = struct{}{}
+	}
+
+	return func(key, value string) string {
+		if value == "" {
+			return value
+		}
+
+		if _, ok := skipExact[key]; ok {
+			return value
+		}
+
+		for _, prefix := range skipPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return value
+			}
+		}
+
+		for _, d := range detectors {
+			value = d.scrub(value)
+		}
+
+		return value
+	}
+}
+
+// scrub replaces every validated match of the detector in value.
+func (d Detector) scrub(value string) string {
+	if d.Pattern == nil {
+		return value
+	}
+
+	return d.Pattern.ReplaceAllStringFunc(value, func(match string) string {
+		if d.Validate != nil && !d.Validate(match) {
+			return match
+		}
+
+		return valueRedacted
+	})
+}
+
+// luhnValid reports whether the digits in s (ignoring spaces and dashes) form
+// a 13-19 digit number passing the Luhn checksum.
+func luhnValid(s string) bool {
+	var (
+		sum    int
+		digits int
+		double bool
+	)
+
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+
+		if c < '0' || c > '9' {
+			return false
+		}
+
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		digits++
+		double = !double
+	}
+
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+// ibanValid reports whether s (ignoring spaces) passes the IBAN mod-97 check.
+func ibanValid(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+
+	// Move the country code and check digits to the end, then convert
+	// letters to numbers (A=10 ... Z=35) and compute the remainder.
+	rearranged := s[4:] + s[:4]
+	rem := 0
+
+	for i := 0; i < len(rearranged); i++ {
+		c := rearranged[i]
+
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+
+	return rem == 1
+}
@@ -0,0 +1,173 @@
+package echootelmiddleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDetectors(t *testing.T) {
+	scrub := NewValueScrubber(AllDetectors())
+
+	for _, tc := range []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "email",
+			value: "contact bob@example.com now",
+			want:  "contact [redacted] now",
+		},
+		{
+			name:  "valid credit card",
+			value: "card 4111 1111 1111 1111 used",
+			want:  "card [redacted] used",
+		},
+		{
+			name:  "number failing luhn is kept",
+			value: "order 1234567890123",
+			want:  "order 1234567890123",
+		},
+		{
+			name:  "jwt",
+			value: "token=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig-_123",
+			want:  "token=[redacted]",
+		},
+		{
+			name:  "bearer token",
+			value: "Bearer abc.def-123",
+			want:  "[redacted]",
+		},
+		{
+			name:  "valid iban",
+			value: "iban GB82 WEST 1234 5698 7654 32 ok",
+			want:  "iban [redacted] ok",
+		},
+		{
+			name:  "invalid iban is kept",
+			value: "GB00WEST12345698765432",
+			want:  "GB00WEST12345698765432",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, scrub("any", tc.value))
+		})
+	}
+}
+
+func TestRegexDetector(t *testing.T) {
+	scrub := NewValueScrubber([]Detector{RegexDetector("ssn", `\b\d{3}-\d{2}-\d{4}\b`)})
+	assert.Equal(t, "ssn [redacted]", scrub("k", "ssn 123-45-6789"))
+}
+
+func TestValueScrubberSkipKeys(t *testing.T) {
+	scrub := NewValueScrubber(PIIDetectors(), "user.email", "http.request.headers.*")
+
+	assert.Equal(t, "bob@example.com", scrub("user.email", "bob@example.com"))
+	assert.Equal(t, "bob@example.com", scrub("http.request.headers.from", "bob@example.com"))
+	assert.Equal(t, "[redacted]", scrub("http.request.body", "bob@example.com"))
+}
+
+func TestValueScrubberInMiddleware(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		AreHeadersDump: true,
+		IsBodyDump:     true,
+		HeaderSkipper:  func(string) bool { return false },
+		ValueScrubber:  NewValueScrubber(AllDetectors()),
+	}))
+	router.POST("/x", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("mail me at bob@example.com"))
+	r.Header.Set(echo.HeaderContentType, "text/plain")
+	r.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("http.request.body", "mail me at [redacted]"))
+	assert.Contains(t, attrs, attribute.StringSlice("http.request.headers.authorization", []string{"[redacted]"}))
+}
+
+func TestValueScrubberCoversErrorsAndStartAttributes(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		handler     echo.HandlerFunc
+		wantMessage string
+	}{
+		{
+			name:        "error",
+			wantMessage: "no user [redacted]",
+			handler: func(*echo.Context) error {
+				return errors.New("no user bob@example.com")
+			},
+		},
+		{
+			name:        "panic",
+			wantMessage: "panic: no user [redacted]",
+			handler: func(*echo.Context) error {
+				panic("no user bob@example.com")
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(middleware.Recover())
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider: provider,
+				ValueScrubber:  NewValueScrubber(AllDetectors()),
+			}))
+			router.GET("/x", tc.handler)
+
+			r := httptest.NewRequest(http.MethodGet, "/x", http.NoBody)
+			r.Header.Set("User-Agent", "bot (contact bob@example.com)")
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+
+			assert.Contains(t, span.Attributes(), attribute.String("user_agent.original", "bot (contact [redacted])"))
+			assert.NotContains(t, span.Status().Description, "bob@example.com")
+			assert.Contains(t, span.Status().Description, "[redacted]")
+
+			var found bool
+
+			for _, event := range span.Events() {
+				if event.Name != "exception" {
+					continue
+				}
+
+				found = true
+
+				for _, attr := range event.Attributes {
+					assert.NotContains(t, attr.Value.Emit(), "bob@example.com", attr.Key)
+				}
+
+				assert.Contains(t, event.Attributes, attribute.String("exception.message", tc.wantMessage))
+			}
+
+			assert.True(t, found, "expected an exception event")
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package echootelmiddleware
+
+import (
+	"github.com/labstack/echo/v5"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// SpanNameFormatter builds the span name for a request. route is the
+// normalized route template (see routeTemplate), or "" when the request did
+// not match a route.
+type SpanNameFormatter func(c *echo.Context, route string) string
+
+// DefaultSpanNameFormatter names spans "{METHOD} {route}", falling back to
+// "HTTP {METHOD}" when the route is unknown. gRPC, gRPC-Web and Connect calls
+// recorded with IsRPC are named "{service}/{method}", and named GraphQL
+// operations recorded with IsGraphQL "{type} {name}".
+func DefaultSpanNameFormatter(c *echo.Context, route string) string {
+	if service, method, ok := RPCCallFromContext(c); ok && method != "" {
+		return service + "/" + method
+	}
+
+	if opType, name, ok := GraphQLOperationFromContext(c); ok && name != "" {
+		return opType + " " + name
+	}
+
+	return createSpanName(c.Request(), route)
+}
+
+// routeTemplate returns the low-cardinality route template matched by the
+// router. Echo's built-in 404 and 405 handlers, and the catch-all not-found
+// routes Echo registers for groups (e.g. "/api/*"), are reported as "" so
+// unmatched requests share one span name instead of borrowing a real route.
+// Wildcard routes are already templated by Echo (e.g. "/static/*").
+func routeTemplate(c *echo.Context) string {
+	ri := c.RouteInfo()
+
+	switch {
+	case ri.Name == echo.NotFoundRouteName,
+		ri.Name == echo.MethodNotAllowedRouteName,
+		ri.Method == echo.RouteNotFound:
+		return ""
+	}
+
+	return c.Path()
+}
+
+// updateRoute renames the span and records http.route and the path
+// parameters when routing resolved only after the span started, e.g. when the
+// middleware is registered with e.Pre().
+func updateRoute(c *echo.Context, config OtelConfig, span oteltrace.Span, startRoute string) {
+	route := routeTemplate(c)
+	if route == startRoute {
+		return
+	}
+
+	span.SetName(config.SpanNameFormatter(c, route))
+	addPathParameters(c, config, span)
+}
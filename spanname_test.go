@@ -0,0 +1,107 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpanNameNormalization(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		method   string
+		target   string
+		wantName string
+	}{
+		{name: "wildcard route", method: http.MethodGet, target: "/static/css/app.css", wantName: "GET /static/*"},
+		{name: "group route", method: http.MethodGet, target: "/api/users/1", wantName: "GET /api/users/:id"},
+		{name: "not found", method: http.MethodGet, target: "/nope", wantName: "HTTP GET"},
+		{name: "method not allowed", method: http.MethodPost, target: "/static/app.css", wantName: "HTTP POST"},
+		{name: "group not found", method: http.MethodGet, target: "/api/nope", wantName: "HTTP GET"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{TracerProvider: provider}))
+			router.GET("/static/*", func(c *echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			api := router.Group("/api")
+			api.Use(func(next echo.HandlerFunc) echo.HandlerFunc { return next })
+			api.GET("/users/:id", func(c *echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			r := httptest.NewRequest(tc.method, tc.target, http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tc.wantName, spans[0].Name())
+
+			if tc.wantName == "HTTP "+tc.method {
+				assert.False(t, hasAttrPrefix(spans[0].Attributes(), routeTag))
+			}
+		})
+	}
+}
+
+func TestSpanNameFormatter(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		SpanNameFormatter: func(c *echo.Context, route string) string {
+			return "svc " + DefaultSpanNameFormatter(c, route)
+		},
+	}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, userURL, http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, "svc GET "+userEndpoint, sr.Ended()[0].Name())
+}
+
+func TestSpanRenamedWhenRegisteredWithPre(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Pre(MiddlewareWithConfig(OtelConfig{TracerProvider: provider}))
+	router.GET(userEndpoint, func(c *echo.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+
+	for _, target := range []string{userURL, "/nope"} {
+		r := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+	}
+
+	spans := sr.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "GET "+userEndpoint, spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String(routeTag, userEndpoint))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("http.path.id", userID))
+
+	assert.Equal(t, "HTTP GET", spans[1].Name())
+	assert.False(t, hasAttrPrefix(spans[1].Attributes(), routeTag))
+}
@@ -0,0 +1,62 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// attrStatusDescription is the key the ValueScrubber sees for the span status
+// description.
+const attrStatusDescription = "otel.status_description"
+
+// StatusClassifier maps the response status code and handler error of a
+// request to a span status code and description. Returning codes.Unset leaves
+// the span status untouched.
+type StatusClassifier func(c *echo.Context, status int, err error) (codes.Code, string)
+
+// LegacyStatusClassifier marks 4xx and 5xx responses as Error and 2xx/3xx
+// responses as Ok, as well as gRPC and Connect server errors of calls detected
+// with IsRPC.
+// This is the default.
+func LegacyStatusClassifier(c *echo.Context, status int, err error) (codes.Code, string) {
+	if description, ok := rpcServerError(c); ok {
+		return codes.Error, description
+	}
+
+	switch {
+	case status >= 400:
+		return codes.Error, statusDescription(status, err)
+	case status >= 200:
+		return codes.Ok, ""
+	}
+
+	return codes.Unset, ""
+}
+
+// SemconvServerStatusClassifier follows the OpenTelemetry HTTP server span
+// conventions: only 5xx responses and gRPC and Connect server errors of calls
+// detected with IsRPC are Error, everything else (including 4xx, which are the client's
+// fault) leaves the status Unset.
+func SemconvServerStatusClassifier(c *echo.Context, status int, err error) (codes.Code, string) {
+	if description, ok := rpcServerError(c); ok {
+		return codes.Error, description
+	}
+
+	if status >= 500 {
+		return codes.Error, statusDescription(status, err)
+	}
+
+	return codes.Unset, ""
+}
+
+// statusDescription returns the handler error message, or the status text
+// when the handler did not return an error.
+func statusDescription(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return http.StatusText(status)
+}
@@ -0,0 +1,78 @@
+package echootelmiddleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSemconvServerStatusClassifier(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		err      error
+		wantCode codes.Code
+		wantDesc string
+	}{
+		{name: "2xx", status: http.StatusOK, wantCode: codes.Unset},
+		{name: "3xx", status: http.StatusFound, wantCode: codes.Unset},
+		{name: "4xx", status: http.StatusNotFound, wantCode: codes.Unset},
+		{name: "5xx", status: http.StatusBadGateway, wantCode: codes.Error, wantDesc: "Bad Gateway"},
+		{name: "5xx with error", status: http.StatusInternalServerError, err: errors.New("boom"), wantCode: codes.Error, wantDesc: "boom"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, desc := SemconvServerStatusClassifier(nil, tc.status, tc.err)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, tc.wantDesc, desc)
+		})
+	}
+}
+
+func TestLegacyStatusClassifierDescription(t *testing.T) {
+	code, desc := LegacyStatusClassifier(nil, http.StatusNotFound, nil)
+	assert.Equal(t, codes.Error, code)
+	assert.Equal(t, "Not Found", desc)
+}
+
+func TestCustomStatusClassifier(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: provider,
+		StatusClassifier: func(c *echo.Context, status int, err error) (codes.Code, string) {
+			if c.Path() == "/items/:id" && (status == http.StatusNotFound || status == http.StatusConflict) {
+				return codes.Unset, ""
+			}
+
+			return SemconvServerStatusClassifier(c, status, err)
+		},
+	}))
+	router.GET("/items/:id", func(_ *echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "no such item")
+	})
+	router.GET("/other", func(_ *echo.Context) error {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "down")
+	})
+
+	for _, target := range []string{"/items/1", "/other"} {
+		r := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+	}
+
+	spans := sr.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "code=503, message=down", spans[1].Status().Description)
+}
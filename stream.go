@@ -0,0 +1,193 @@
+package echootelmiddleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/adlandh/response-dumper"
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const mimeEventStream = "text/event-stream"
+
+// Attribute keys for streamed responses.
+const (
+	attrResponseStreaming = "http.response.streaming"
+	attrResponseFlushes   = "http.response.flushes"
+)
+
+// streamWriter wraps the response writer to count flushes. Flush and Hijack
+// go through http.ResponseController so they reach the underlying writer
+// through any number of wrappers.
+type streamWriter struct {
+	http.ResponseWriter
+
+	flushes int64
+	onFlush func()
+	bound   func() // caps an unbounded response dump, if any
+}
+
+func (w *streamWriter) Flush() {
+	if err := http.NewResponseController(w.ResponseWriter).Flush(); err != nil {
+		return
+	}
+
+	w.flushes++
+	w.onFlush()
+
+	if w.bound != nil {
+		w.bound()
+	}
+}
+
+func (w *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// shouldWatchStream reports whether a streamWriter is needed: to detect or
+// mark streams, to record flush events, or to notice streams whose response
+// body is dumped without a limit.
+func shouldWatchStream(config OtelConfig, skipRespBody bool) bool {
+	return config.DetectStreaming || config.StreamingResponse || config.RecordLifecycleEvents ||
+		(config.IsBodyDump && !skipRespBody && config.MaxBodyDumpSize < 0)
+}
+
+// watchStream installs a streamWriter that records a response.flush event
+// per flush if RecordLifecycleEvents is enabled.
+func watchStream(c *echo.Context, config OtelConfig, span oteltrace.Span) *streamWriter {
+	w := &streamWriter{ResponseWriter: c.Response()}
+	w.onFlush = func() {
+		addEvent(span, config, eventResponseFlush, attribute.Int64(eventAttrBytes, responseSize(c)))
+	}
+
+	c.SetResponse(w)
+
+	return w
+}
+
+// streamDumper wraps the response dumper so a stream stops being buffered:
+// once bound is called, bytes past defaultMaxBodyDumpSize go around the
+// dumper's buffer to the underlying writer, and Body drops anything buffered
+// beyond it before the stream was noticed.
+type streamDumper struct {
+	*response.Dumper
+
+	limit       int   // 0 until the response turns out to be a stream
+	skipped     int64 // bytes written around the buffer
+	wroteHeader bool
+}
+
+func (d *streamDumper) WriteHeader(code int) {
+	d.wroteHeader = true
+	d.Dumper.WriteHeader(code)
+}
+
+func (d *streamDumper) Write(b []byte) (int, error) {
+	// Write the header first so a text/event-stream response is bound
+	// before its first bytes reach the buffer.
+	if !d.wroteHeader {
+		d.WriteHeader(http.StatusOK)
+	}
+
+	if d.limit <= 0 {
+		return d.Dumper.Write(b)
+	}
+
+	n := 0
+
+	if room := d.limit - d.Dumper.BytesWritten(); room > 0 {
+		var err error
+
+		n, err = d.Dumper.Write(b[:min(room, len(b))])
+		if err != nil || n == len(b) {
+			return n, err
+		}
+	}
+
+	m, err := d.Dumper.ResponseWriter.Write(b[n:])
+	d.skipped += int64(m)
+
+	return n + m, err
+}
+
+// ReadFrom routes io.Copy through Write, like response.Dumper does.
+func (d *streamDumper) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{d}, r)
+}
+
+// Body returns the buffered body, at most limit bytes once bound.
+func (d *streamDumper) Body() []byte {
+	body := d.Dumper.Body()
+	if d.limit > 0 && len(body) > d.limit {
+		return body[:d.limit]
+	}
+
+	return body
+}
+
+// BytesWritten returns the number of body bytes written to the client,
+// including those written around the buffer.
+func (d *streamDumper) BytesWritten() int {
+	return d.Dumper.BytesWritten() + int(d.skipped)
+}
+
+// bound stops buffering past defaultMaxBodyDumpSize.
+func (d *streamDumper) bound() {
+	d.limit = int(defaultMaxBodyDumpSize)
+}
+
+// boundStreamDump bounds an unlimited response dump once the response turns
+// out to be a stream: when the headers are written with a text/event-stream
+// content type, or on the first handler flush.
+func boundStreamDump(c *echo.Context, config OtelConfig, w *streamWriter, respDumper *streamDumper) {
+	if respDumper == nil || config.MaxBodyDumpSize >= 0 {
+		return
+	}
+
+	if w != nil {
+		w.bound = respDumper.bound
+	}
+
+	resp, err := echo.UnwrapResponse(c.Response())
+	if err != nil || resp == nil {
+		return
+	}
+
+	resp.Before(func() {
+		if mediaType(resp.Header().Get(echo.HeaderContentType)) == mimeEventStream {
+			respDumper.bound()
+		}
+	})
+}
+
+// isStreaming reports whether the response was streamed: flagged by
+// StreamingResponse or, with DetectStreaming, flushed by the handler or sent
+// as Server-Sent Events.
+func isStreaming(c *echo.Context, config OtelConfig, w *streamWriter) bool {
+	if config.StreamingResponse {
+		return true
+	}
+
+	return config.DetectStreaming && (w.flushes > 0 ||
+		mediaType(c.Response().Header().Get(echo.HeaderContentType)) == mimeEventStream)
+}
+
+// recordStream marks streamed responses on the span with their flush count.
+func recordStream(c *echo.Context, config OtelConfig, span oteltrace.Span, w *streamWriter) {
+	if !isStreaming(c, config, w) {
+		return
+	}
+
+	setAttr(span, config,
+		attribute.Bool(attrResponseStreaming, true),
+		attribute.Int64(attrResponseFlushes, w.flushes),
+	)
+}
@@ -0,0 +1,300 @@
+package echootelmiddleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adlandh/response-dumper"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestServerSentEvents(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:        sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsBodyDump:            true,
+		MaxBodyDumpSize:       16,
+		RecordLifecycleEvents: true,
+		DetectStreaming:       true,
+	}))
+	router.GET("/events", func(c *echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, mimeEventStream)
+		c.Response().WriteHeader(http.StatusOK)
+
+		for _, msg := range []string{"one", "two"} {
+			if _, err := c.Response().Write([]byte("data: " + msg + "\n\n")); err != nil {
+				return err
+			}
+
+			if err := http.NewResponseController(c.Response()).Flush(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", http.NoBody))
+
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "data: one\n\ndata: two\n\n", w.Body.String())
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	attrs := spans[0].Attributes()
+
+	assert.Contains(t, attrs, attribute.Bool("http.response.streaming", true))
+	assert.Contains(t, attrs, attribute.Int64("http.response.flushes", 2))
+	assert.Contains(t, attrs, attribute.String("http.response.body", "data: one\n\ndata:[truncated]"))
+
+	var flushed []int64
+
+	for _, e := range spans[0].Events() {
+		if e.Name == "response.flush" {
+			require.Len(t, e.Attributes, 1)
+			flushed = append(flushed, e.Attributes[0].Value.AsInt64())
+		}
+	}
+
+	assert.Equal(t, []int64{11, 22}, flushed)
+}
+
+func TestStreamingDetection(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  OtelConfig
+		handler echo.HandlerFunc
+		want    bool
+	}{
+		{
+			name: "plain response",
+			handler: func(c *echo.Context) error {
+				return c.String(http.StatusOK, "ok")
+			},
+		},
+		{
+			name: "detection disabled",
+			handler: func(c *echo.Context) error {
+				return c.Blob(http.StatusOK, mimeEventStream, []byte("data: x\n\n"))
+			},
+		},
+		{
+			name:   "flushed response",
+			config: OtelConfig{DetectStreaming: true},
+			handler: func(c *echo.Context) error {
+				_, _ = c.Response().Write([]byte("chunk"))
+				c.Response().(http.Flusher).Flush()
+
+				return nil
+			},
+			want: true,
+		},
+		{
+			name:   "event stream without flush",
+			config: OtelConfig{DetectStreaming: true},
+			handler: func(c *echo.Context) error {
+				return c.Blob(http.StatusOK, mimeEventStream, []byte("data: x\n\n"))
+			},
+			want: true,
+		},
+		{
+			name:   "streaming flag",
+			config: OtelConfig{StreamingResponse: true},
+			handler: func(c *echo.Context) error {
+				return c.String(http.StatusOK, "ok")
+			},
+			want: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			tc.config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(tc.config))
+			router.GET("/", tc.handler)
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tc.want, hasAttrPrefix(spans[0].Attributes(), "http.response.streaming"))
+		})
+	}
+}
+
+func TestStreamingRouteBoundsUnlimitedDump(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	body := strings.Repeat("x", int(defaultMaxBodyDumpSize)+1)
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:  sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsBodyDump:      true,
+		MaxBodyDumpSize: -1,
+		RouteOverrides: []RouteOverride{{
+			Path:  "/download",
+			Apply: func(c *OtelConfig) { c.StreamingResponse = true },
+		}},
+	}))
+	router.GET("/download", func(c *echo.Context) error {
+		return c.String(http.StatusOK, body)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download", http.NoBody))
+	assert.Equal(t, body, w.Body.String())
+
+	attrs := sr.Ended()[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("http.response.body", body[:defaultMaxBodyDumpSize]+"[truncated]"))
+}
+
+func TestStreamBoundsUnlimitedDump(t *testing.T) {
+	chunk := strings.Repeat("x", int(defaultMaxBodyDumpSize))
+
+	for _, tc := range []struct {
+		name    string
+		handler echo.HandlerFunc
+	}{
+		{
+			name: "event stream",
+			handler: func(c *echo.Context) error {
+				return c.Blob(http.StatusOK, mimeEventStream, []byte(chunk+chunk))
+			},
+		},
+		{
+			name: "event stream written in small chunks",
+			handler: func(c *echo.Context) error {
+				c.Response().Header().Set(echo.HeaderContentType, mimeEventStream)
+
+				for range 2 * int(defaultMaxBodyDumpSize) {
+					if _, err := c.Response().Write([]byte("x")); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "buffered before the first flush",
+			handler: func(c *echo.Context) error {
+				c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlain)
+
+				if _, err := io.WriteString(c.Response(), chunk+chunk); err != nil {
+					return err
+				}
+
+				c.Response().(http.Flusher).Flush()
+
+				return nil
+			},
+		},
+		{
+			name: "flushed response",
+			handler: func(c *echo.Context) error {
+				c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlain)
+				c.Response().WriteHeader(http.StatusOK)
+				c.Response().(http.Flusher).Flush()
+
+				_, err := c.Response().Write([]byte(chunk + chunk))
+
+				return err
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+
+			// Detection is off: the stream is still noticed to bound the dump.
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:  sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+				IsBodyDump:      true,
+				MaxBodyDumpSize: -1,
+				BodySkipper:     func(*echo.Context) (bool, bool) { return false, false },
+			}))
+			router.GET("/", tc.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			assert.Equal(t, chunk+chunk, w.Body.String())
+
+			attrs := sr.Ended()[0].Attributes()
+			assert.Contains(t, attrs, attribute.String("http.response.body", chunk+"[truncated]"))
+			assert.False(t, hasAttrPrefix(attrs, "http.response.streaming"))
+		})
+	}
+}
+
+func TestStreamWriterOnlyWhenNeeded(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config OtelConfig
+		want   bool
+	}{
+		{name: "default"},
+		{name: "bounded body dump", config: OtelConfig{IsBodyDump: true}},
+		{name: "detection", config: OtelConfig{DetectStreaming: true}, want: true},
+		{name: "streaming flag", config: OtelConfig{StreamingResponse: true}, want: true},
+		{name: "lifecycle events", config: OtelConfig{RecordLifecycleEvents: true}, want: true},
+		{name: "unbounded body dump", config: OtelConfig{IsBodyDump: true, MaxBodyDumpSize: -1}, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var watched bool
+
+			tc.config.TracerProvider = sdktrace.NewTracerProvider()
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(tc.config))
+			router.GET("/", func(c *echo.Context) error {
+				for w := c.Response(); w != nil; {
+					if _, ok := w.(*streamWriter); ok {
+						watched = true
+					}
+
+					u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+					if !ok {
+						break
+					}
+
+					w = u.Unwrap()
+				}
+
+				return c.NoContent(http.StatusOK)
+			})
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+			assert.Equal(t, tc.want, watched)
+		})
+	}
+}
+
+func TestStreamDumperStopsBuffering(t *testing.T) {
+	w := httptest.NewRecorder()
+	d := &streamDumper{Dumper: response.NewDumper(w, response.WithMaxBytes(0))}
+	d.bound()
+
+	chunk := strings.Repeat("x", int(defaultMaxBodyDumpSize))
+	for range 3 {
+		_, err := d.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+
+	// The client gets every byte; the buffer stops at the limit.
+	assert.Equal(t, 3*len(chunk), w.Body.Len())
+	assert.Len(t, d.Dumper.Body(), len(chunk))
+	assert.Equal(t, 3*len(chunk), d.BytesWritten())
+}
@@ -0,0 +1,74 @@
+package echootelmiddleware
+
+import (
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// TraceResponseHeaders is a bit set selecting which trace context headers are
+// written on the response.
+type TraceResponseHeaders uint8
+
+const (
+	// TraceResponseHeader writes the W3C Trace Context `traceresponse` header.
+	TraceResponseHeader TraceResponseHeaders = 1 << iota
+
+	// ServerTimingHeader appends a `Server-Timing: traceparent;desc="..."`
+	// entry, which browsers expose through the Resource Timing API.
+	ServerTimingHeader
+)
+
+const (
+	headerTraceResponse = "Traceresponse"
+	headerServerTiming  = "Server-Timing"
+)
+
+// TrustedOriginsSkipper returns a Skipper for TraceResponseSkipper that only
+// allows trace response headers for requests whose Origin header matches one
+// of the given origins (e.g. "https://app.example.com"). Requests without an
+// Origin header (same-origin navigations, non-browser clients) are skipped
+// unless allowNoOrigin is set.
+func TrustedOriginsSkipper(allowNoOrigin bool, origins ...string) middleware.Skipper {
+	trusted := make(map[string]struct{}, len(origins))
+	for _, o := range origins {
+		trusted[o] = struct{}{}
+	}
+
+	return func(c *echo.Context) bool {
+		origin := c.Request().Header.Get(echo.HeaderOrigin)
+		if origin == "" {
+			return !allowNoOrigin
+		}
+
+		_, ok := trusted[origin]
+
+		return !ok
+	}
+}
+
+// formatTraceparent formats a span context in the W3C traceparent format:
+// "00-{trace-id}-{span-id}-{flags}".
+func formatTraceparent(sc oteltrace.SpanContext) string {
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+}
+
+// writeTraceResponseHeaders writes the configured trace context headers for
+// the server span. It runs before the handler so the headers are in place
+// before the response is flushed.
+func writeTraceResponseHeaders(c *echo.Context, config OtelConfig, sc oteltrace.SpanContext) {
+	if config.TraceResponseHeaders == 0 || !sc.IsValid() || config.TraceResponseSkipper(c) {
+		return
+	}
+
+	traceparent := formatTraceparent(sc)
+	header := c.Response().Header()
+
+	if config.TraceResponseHeaders&TraceResponseHeader != 0 {
+		header.Set(headerTraceResponse, traceparent)
+	}
+
+	if config.TraceResponseHeaders&ServerTimingHeader != 0 {
+		header.Add(headerServerTiming, `traceparent;desc="`+traceparent+`"`)
+	}
+}
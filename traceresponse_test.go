@@ -0,0 +1,137 @@
+package echootelmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceResponseHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		headers          TraceResponseHeaders
+		wantTraceResp    bool
+		wantServerTiming bool
+	}{
+		{
+			name: "disabled by default",
+		},
+		{
+			name:          "traceresponse only",
+			headers:       TraceResponseHeader,
+			wantTraceResp: true,
+		},
+		{
+			name:             "server-timing only",
+			headers:          ServerTimingHeader,
+			wantServerTiming: true,
+		},
+		{
+			name:             "both",
+			headers:          TraceResponseHeader | ServerTimingHeader,
+			wantTraceResp:    true,
+			wantServerTiming: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:       provider,
+				TraceResponseHeaders: tc.headers,
+			}))
+			router.GET("/ping", func(c *echo.Context) error {
+				return c.String(http.StatusOK, "ok")
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/ping", http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			want := formatTraceparent(spans[0].SpanContext())
+
+			if tc.wantTraceResp {
+				assert.Equal(t, want, w.Header().Get("traceresponse"))
+			} else {
+				assert.Empty(t, w.Header().Get("traceresponse"))
+			}
+
+			if tc.wantServerTiming {
+				assert.Equal(t, `traceparent;desc="`+want+`"`, w.Header().Get("Server-Timing"))
+			} else {
+				assert.Empty(t, w.Header().Get("Server-Timing"))
+			}
+		})
+	}
+}
+
+func TestFormatTraceparent(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	_, span := provider.Tracer(tracerName).Start(t.Context(), "test")
+	sc := span.SpanContext()
+	span.End()
+
+	assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", formatTraceparent(sc))
+}
+
+func TestTrustedOriginsSkipper(t *testing.T) {
+	e := echo.New()
+
+	for _, tc := range []struct {
+		name          string
+		allowNoOrigin bool
+		origin        string
+		wantSkip      bool
+	}{
+		{name: "no origin", origin: "", wantSkip: true},
+		{name: "no origin allowed", allowNoOrigin: true, origin: "", wantSkip: false},
+		{name: "trusted origin", origin: "https://app.example.com", wantSkip: false},
+		{name: "untrusted origin", origin: "https://evil.example.com", wantSkip: true},
+		{name: "untrusted origin with no origin allowed", allowNoOrigin: true, origin: "https://evil.example.com", wantSkip: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tc.origin != "" {
+				r.Header.Set(echo.HeaderOrigin, tc.origin)
+			}
+
+			c := e.NewContext(r, httptest.NewRecorder())
+			assert.Equal(t, tc.wantSkip, TrustedOriginsSkipper(tc.allowNoOrigin, "https://app.example.com")(c))
+		})
+	}
+}
+
+func TestTraceResponseSkippedForUntrustedOrigin(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:       provider,
+		TraceResponseHeaders: TraceResponseHeader | ServerTimingHeader,
+		TraceResponseSkipper: TrustedOriginsSkipper(false, "https://app.example.com"),
+	}))
+	router.GET("/ping", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/ping", http.NoBody)
+	r.Header.Set(echo.HeaderOrigin, "https://evil.example.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Empty(t, w.Header().Get("traceresponse"))
+	assert.Empty(t, w.Header().Get("Server-Timing"))
+}
@@ -0,0 +1,49 @@
+package echootelmiddleware
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// TrustedNetworksSkipper returns a Skipper for InboundContextSkipper that
+// trusts the inbound trace context only for requests whose c.RealIP() is in
+// one of the given CIDR prefixes (e.g. "10.0.0.0/8"). It panics if a prefix
+// cannot be parsed.
+func TrustedNetworksSkipper(cidrs ...string) middleware.Skipper {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+
+	return func(c *echo.Context) bool {
+		addr, err := netip.ParseAddr(c.RealIP())
+		if err != nil {
+			return true
+		}
+
+		addr = addr.Unmap()
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// untrustedParentOptions returns span options that start a new root span and
+// link the remote span context extracted into ctx, if any.
+func untrustedParentOptions(ctx context.Context) []oteltrace.SpanStartOption {
+	opts := []oteltrace.SpanStartOption{oteltrace.WithNewRoot()}
+
+	if remote := oteltrace.SpanContextFromContext(ctx); remote.IsValid() {
+		opts = append(opts, oteltrace.WithLinks(oteltrace.Link{SpanContext: remote}))
+	}
+
+	return opts
+}
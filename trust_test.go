@@ -0,0 +1,166 @@
+package echootelmiddleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	remoteTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteSpanID      = "00f067aa0ba902b7"
+	remoteTraceparent = "00-" + remoteTraceID + "-" + remoteSpanID + "-01"
+)
+
+func TestInboundContextSkipper(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		skipper middleware.Skipper
+		trusted bool
+	}{
+		{
+			name:    "trusted by default",
+			trusted: true,
+		},
+		{
+			name:    "untrusted",
+			skipper: func(*echo.Context) bool { return true },
+		},
+		{
+			name:    "trusted network",
+			skipper: TrustedNetworksSkipper("192.0.2.0/24"),
+			trusted: true,
+		},
+		{
+			name:    "untrusted network",
+			skipper: TrustedNetworksSkipper("10.0.0.0/8"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:        sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+				Propagator:            propagation.TraceContext{},
+				InboundContextSkipper: tc.skipper,
+			}))
+			router.GET("/", func(c *echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			r.Header.Set("Traceparent", remoteTraceparent)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+
+			if tc.trusted {
+				assert.Equal(t, remoteTraceID, span.SpanContext().TraceID().String())
+				assert.Equal(t, remoteSpanID, span.Parent().SpanID().String())
+				assert.Empty(t, span.Links())
+
+				return
+			}
+
+			assert.NotEqual(t, remoteTraceID, span.SpanContext().TraceID().String())
+			assert.False(t, span.Parent().IsValid())
+			require.Len(t, span.Links(), 1)
+			assert.Equal(t, remoteTraceID, span.Links()[0].SpanContext.TraceID().String())
+			assert.Equal(t, remoteSpanID, span.Links()[0].SpanContext.SpanID().String())
+		})
+	}
+}
+
+func TestUntrustedWithoutRemoteContext(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:        sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		InboundContextSkipper: func(*echo.Context) bool { return true },
+	}))
+	router.GET("/", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Empty(t, spans[0].Links())
+}
+
+func TestUntrustedBaggageDropped(t *testing.T) {
+	for _, trusted := range []bool{true, false} {
+		t.Run(fmt.Sprintf("trusted=%v", trusted), func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+
+			var handlerBaggage string
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider:        sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+				Propagator:            propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+				InboundContextSkipper: func(*echo.Context) bool { return !trusted },
+				IsBaggageDump:         true,
+			}))
+			router.GET("/", func(c *echo.Context) error {
+				handlerBaggage = baggage.FromContext(c.Request().Context()).Member("tenant.id").Value()
+
+				return c.NoContent(http.StatusOK)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			r.Header.Set("Traceparent", remoteTraceparent)
+			r.Header.Set("Baggage", "tenant.id=acme")
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+
+			if trusted {
+				assert.Equal(t, "acme", handlerBaggage)
+				assert.Contains(t, spans[0].Attributes(), attribute.String("baggage.tenant.id", "acme"))
+
+				return
+			}
+
+			assert.Empty(t, handlerBaggage)
+			assert.False(t, hasAttrPrefix(spans[0].Attributes(), "baggage."))
+		})
+	}
+}
+
+func TestTrustedNetworksSkipper(t *testing.T) {
+	skip := TrustedNetworksSkipper("10.0.0.0/8", "2001:db8::/32")
+	e := echo.New()
+
+	for addr, want := range map[string]bool{
+		"10.1.2.3:1234":          false,
+		"[::ffff:10.1.2.3]:1234": false,
+		"[2001:db8::1]:1234":     false,
+		"192.0.2.1:1234":         true,
+		"not-an-ip:1234":         true,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		r.RemoteAddr = addr
+		assert.Equal(t, want, skip(e.NewContext(r, httptest.NewRecorder())), addr)
+	}
+
+	assert.Panics(t, func() { TrustedNetworksSkipper("bogus") })
+}
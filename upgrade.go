@@ -0,0 +1,209 @@
+package echootelmiddleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const connectionKey = "echo-otel-middleware-connection"
+
+// Connection span attribute keys and event names.
+const (
+	attrConnectionMessagesSent     = "connection.messages.sent"
+	attrConnectionMessagesReceived = "connection.messages.received"
+	attrConnectionCloseCode        = "connection.close.code"
+	attrConnectionCloseReason      = "connection.close.reason"
+	eventConnectionClose           = "connection.close"
+)
+
+// isUpgradeRequest reports whether the request asks for a protocol upgrade
+// (Connection: Upgrade with an Upgrade header), e.g. a WebSocket handshake.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+
+	for _, v := range r.Header.Values("Connection") {
+		for token := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// upgradeWriter wraps the response writer of an upgrade request and calls
+// onUpgrade once, when the handler writes 101 Switching Protocols or hijacks
+// the connection.
+type upgradeWriter struct {
+	http.ResponseWriter
+
+	once      sync.Once
+	onUpgrade func()
+	wrapConn  func(net.Conn) net.Conn
+}
+
+func (w *upgradeWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+
+	if code == http.StatusSwitchingProtocols {
+		w.once.Do(w.onUpgrade)
+	}
+}
+
+func (w *upgradeWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *upgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+
+	w.once.Do(w.onUpgrade)
+
+	return w.wrapConn(conn), rw, nil
+}
+
+func (w *upgradeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// connection tracks the span of an upgraded connection.
+type connection struct {
+	span     oteltrace.Span
+	sent     atomic.Int64
+	received atomic.Int64
+	hijacked atomic.Bool
+	once     sync.Once
+}
+
+func (cn *connection) end() {
+	cn.once.Do(func() {
+		cn.span.SetAttributes(
+			attribute.Int64(attrConnectionMessagesSent, cn.sent.Load()),
+			attribute.Int64(attrConnectionMessagesReceived, cn.received.Load()),
+		)
+		cn.span.End()
+	})
+}
+
+// tracedConn ends the connection span when the hijacked connection is closed.
+type tracedConn struct {
+	net.Conn
+
+	conn *connection
+}
+
+func (t *tracedConn) Close() error {
+	err := t.Conn.Close()
+	t.conn.end()
+
+	return err
+}
+
+// traceUpgrade wraps the response writer so the HTTP span is finished when the
+// connection is upgraded: finish records the 101 response and ends the span.
+// With TraceUpgradedConnections a connection span is then started as a child
+// of the HTTP span. The returned function reports whether the upgrade
+// happened and ends a connection span that was never hijacked.
+func traceUpgrade(c *echo.Context, config OtelConfig, span oteltrace.Span, finish func()) func() bool {
+	var (
+		upgraded atomic.Bool
+		conn     *connection
+	)
+
+	w := &upgradeWriter{ResponseWriter: c.Response()}
+	w.onUpgrade = func() {
+		upgraded.Store(true)
+		finish()
+
+		if !config.TraceUpgradedConnections {
+			return
+		}
+
+		request := c.Request()
+		protocol := strings.ToLower(request.Header.Get("Upgrade"))
+
+		name := protocol
+		if route := routeTemplate(c); route != "" {
+			name += " " + route
+		}
+
+		tracer := config.TracerProvider.Tracer(tracerName)
+		ctx, connSpan := tracer.Start(oteltrace.ContextWithSpan(request.Context(), span), name,
+			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+			oteltrace.WithAttributes(semconv.NetworkProtocolName(protocol)),
+		)
+
+		conn = &connection{span: connSpan}
+		c.Set(connectionKey, conn)
+		c.SetRequest(request.WithContext(ctx))
+	}
+	w.wrapConn = func(nc net.Conn) net.Conn {
+		if conn == nil {
+			return nc
+		}
+
+		conn.hijacked.Store(true)
+
+		return &tracedConn{Conn: nc, conn: conn}
+	}
+
+	c.SetResponse(w)
+
+	return func() bool {
+		if conn != nil && !conn.hijacked.Load() {
+			conn.end()
+		}
+
+		return upgraded.Load()
+	}
+}
+
+// connectionFromContext returns the connection traced for c, if any.
+func connectionFromContext(c *echo.Context) *connection {
+	conn, _ := c.Get(connectionKey).(*connection)
+
+	return conn
+}
+
+// ConnectionMessageSent counts a message sent on a connection traced with
+// TraceUpgradedConnections. It is a no-op for other requests.
+func ConnectionMessageSent(c *echo.Context) {
+	if conn := connectionFromContext(c); conn != nil {
+		conn.sent.Add(1)
+	}
+}
+
+// ConnectionMessageReceived counts a message received on a connection traced
+// with TraceUpgradedConnections. It is a no-op for other requests.
+func ConnectionMessageReceived(c *echo.Context) {
+	if conn := connectionFromContext(c); conn != nil {
+		conn.received.Add(1)
+	}
+}
+
+// ConnectionClosed records a close event with the given close code and reason
+// (e.g. a WebSocket close frame) on a connection traced with
+// TraceUpgradedConnections. It is a no-op for other requests.
+func ConnectionClosed(c *echo.Context, code int, reason string) {
+	if conn := connectionFromContext(c); conn != nil {
+		conn.span.AddEvent(eventConnectionClose, oteltrace.WithAttributes(
+			attribute.Int(attrConnectionCloseCode, code),
+			attribute.String(attrConnectionCloseReason, reason),
+		))
+	}
+}
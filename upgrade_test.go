@@ -0,0 +1,219 @@
+package echootelmiddleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestIsUpgradeRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		connection []string
+		upgrade    string
+		want       bool
+	}{
+		{name: "websocket", connection: []string{"Upgrade"}, upgrade: "websocket", want: true},
+		{name: "token list", connection: []string{"keep-alive, upgrade"}, upgrade: "websocket", want: true},
+		{name: "multiple header lines", connection: []string{"keep-alive", "Upgrade"}, upgrade: "h2c", want: true},
+		{name: "missing upgrade header", connection: []string{"Upgrade"}},
+		{name: "missing connection token", connection: []string{"keep-alive"}, upgrade: "websocket"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			for _, v := range tc.connection {
+				r.Header.Add("Connection", v)
+			}
+
+			if tc.upgrade != "" {
+				r.Header.Set("Upgrade", tc.upgrade)
+			}
+
+			assert.Equal(t, tc.want, isUpgradeRequest(r))
+		})
+	}
+}
+
+func TestUpgradeHijack(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	endedAtUpgrade := 0
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider:           sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+		IsBodyDump:               true,
+		TraceUpgradedConnections: true,
+	}))
+	router.GET("/ws", func(c *echo.Context) error {
+		conn, rw, err := http.NewResponseController(c.Response()).Hijack()
+		if err != nil {
+			return err
+		}
+
+		endedAtUpgrade = len(sr.Ended())
+
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+
+		ConnectionMessageReceived(c)
+		ConnectionMessageSent(c)
+		ConnectionMessageSent(c)
+		ConnectionClosed(c, 1000, "bye")
+
+		return conn.Close()
+	})
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	require.Eventually(t, func() bool { return len(sr.Ended()) == 2 }, time.Second, time.Millisecond)
+
+	// The HTTP span ends at upgrade time, before the connection span.
+	assert.Equal(t, 1, endedAtUpgrade)
+
+	spans := sr.Ended()
+	httpSpan, connSpan := spans[0], spans[1]
+
+	assert.Equal(t, "GET /ws", httpSpan.Name())
+	assert.Contains(t, httpSpan.Attributes(), attribute.Int("http.response.status_code", http.StatusSwitchingProtocols))
+	assert.False(t, hasAttrPrefix(httpSpan.Attributes(), "http.request.body"))
+	assert.False(t, hasAttrPrefix(httpSpan.Attributes(), "http.response.body"))
+
+	assert.Equal(t, "websocket /ws", connSpan.Name())
+	assert.Equal(t, httpSpan.SpanContext().SpanID(), connSpan.Parent().SpanID())
+	assert.Contains(t, connSpan.Attributes(), attribute.String("network.protocol.name", "websocket"))
+	assert.Contains(t, connSpan.Attributes(), attribute.Int64("connection.messages.sent", 2))
+	assert.Contains(t, connSpan.Attributes(), attribute.Int64("connection.messages.received", 1))
+
+	require.Len(t, connSpan.Events(), 1)
+	assert.Equal(t, "connection.close", connSpan.Events()[0].Name)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.Int("connection.close.code", 1000),
+		attribute.String("connection.close.reason", "bye"),
+	}, connSpan.Events()[0].Attributes)
+}
+
+func TestUpgradeSwitchingProtocolsStatus(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+	}))
+	router.GET("/upgrade", func(c *echo.Context) error {
+		c.Response().WriteHeader(http.StatusSwitchingProtocols)
+
+		// Messages are ignored without TraceUpgradedConnections.
+		ConnectionMessageSent(c)
+
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/upgrade", http.NoBody)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "h2c")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusSwitchingProtocols))
+}
+
+func TestUpgradeEndsActiveRequest(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	activeRequests := func() int64 {
+		active, ok := collectMetrics(t, reader)["http.server.active_requests"].Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, active.DataPoints, 1)
+
+		return active.DataPoints[0].Value
+	}
+
+	var beforeUpgrade, afterUpgrade int64
+
+	router := echo.New()
+	router.Use(MiddlewareWithConfig(OtelConfig{MeterProvider: mp}))
+	router.GET("/ws", func(c *echo.Context) error {
+		beforeUpgrade = activeRequests()
+		c.Response().WriteHeader(http.StatusSwitchingProtocols)
+		// The upgraded connection is no longer an in-flight HTTP request.
+		afterUpgrade = activeRequests()
+
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", http.NoBody)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, int64(1), beforeUpgrade)
+	assert.Equal(t, int64(0), afterUpgrade)
+	// Decremented exactly once.
+	assert.Equal(t, int64(0), activeRequests())
+}
+
+func TestUpgradeNotPerformed(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		upgrade string
+		status  int
+	}{
+		{name: "h2c", upgrade: "h2c", status: http.StatusOK},
+		{name: "rejected websocket", upgrade: "websocket", status: http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := tracetest.NewSpanRecorder()
+
+			router := echo.New()
+			router.Use(MiddlewareWithConfig(OtelConfig{
+				TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)),
+				IsBodyDump:     true,
+			}))
+			router.POST("/upgrade", func(c *echo.Context) error {
+				return c.JSONBlob(tc.status, []byte(`{"ok":false}`))
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/upgrade", strings.NewReader(`{"name":"bob"}`))
+			r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", tc.upgrade)
+			router.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+
+			attrs := spans[0].Attributes()
+			assert.Contains(t, attrs, attribute.Int("http.response.status_code", tc.status))
+			assert.Contains(t, attrs, attribute.String("http.request.body", `{"name":"bob"}`))
+			assert.Contains(t, attrs, attribute.String("http.response.body", `{"ok":false}`))
+		})
+	}
+}